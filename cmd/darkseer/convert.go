@@ -2,9 +2,12 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"math"
 	"strings"
 
 	"github.com/aoisensi/darkseer/dmx"
+	"github.com/aoisensi/darkseer/dmx/dmxmath"
 	"github.com/qmuntal/gltf"
 	"github.com/qmuntal/gltf/modeler"
 	"github.com/samber/lo"
@@ -13,12 +16,12 @@ import (
 func convertModel(title string, dmxElement *dmx.DmElement) (*gltf.Document, error) {
 	fmt.Println(title)
 	doc := gltf.NewDocument()
-	doc.Scene = gltf.Index(0)
-	scene := &gltf.Scene{}
-	doc.Scenes = append(doc.Scenes, scene)
+	scene := doc.Scenes[*doc.Scene]
+	scene.Name = title
 
+	pose := snapshotPose(dmxElement)
 	materialMap := make(map[string]*uint32)
-	jointMap := make(map[string]uint32)
+	nodeMap := make(map[string]uint32)
 
 	getMaterialID := func(mtlName string) *uint32 {
 		if id, ok := materialMap[mtlName]; ok {
@@ -32,132 +35,337 @@ func convertModel(title string, dmxElement *dmx.DmElement) (*gltf.Document, erro
 		return &id
 	}
 
-	var skinID *uint32
+	// Build the node hierarchy
+	type skinRoot struct {
+		node   uint32
+		joints []uint32
+	}
+	type meshNode struct {
+		mesh *dmx.DmeMesh
+		node uint32
+	}
+	// dagParent is where addDag places a dag: under node, or in the scene
+	// if node is nil. offset is the transform of the model or of the dropped
+	// joints in between, and joint is the nearest exported joint, which
+	// takes over the weights of dropped joints.
+	type dagParent struct {
+		node   *gltf.Node
+		root   *skinRoot
+		joint  *uint32
+		offset *dmxmath.Mat4
+	}
+	var skinRoots []*skinRoot
+	var meshNodes []meshNode
+	jointNodes := make(map[string]uint32)
+	// nodeOffsets holds the offsets folded into the nodes placed under
+	// dropped joints or a transformed model, which their animation keys need
+	// too. droppedJoints names the joints left out.
+	nodeOffsets := make(map[uint32]dmxmath.Mat4)
+	droppedJoints := make(map[string]bool)
+	// jointChildren holds where the children of each exported joint went,
+	// for a model that shares its joints with a separate skeleton.
+	jointChildren := make(map[string]dagParent)
+	var addDag func(dmxDag dmx.IDag, parent dagParent)
+	addDag = func(dmxDag dmx.IDag, parent dagParent) {
+		dag := dmxDag.Dag()
+		_, isJoint := dmxDag.(*dmx.DmeJoint)
+		// The model may share its joints with a separate skeleton.
+		if children, found := jointChildren[dag.Name]; found && isJoint {
+			for _, child := range dag.Children {
+				addDag(child, children)
+			}
+			return
+		}
+		node := dagNode(dag)
+		// Under -pose the joints take the pose the skinned meshes are baked
+		// in, so that the dags hanging off them follow.
+		if pose != nil && isJoint {
+			if jointPose := pose.Joint(dag.Name); jointPose != nil {
+				node.Translation = coords.point(jointPose.Position)
+				node.Rotation = coords.quat(jointPose.Orientation)
+				node.Scale = coords.scaleVector(jointPose.Scale)
+			}
+		}
+		if parent.offset != nil {
+			setLocalMatrix(node, parent.offset.Mul(localMatrix(node)))
+		}
+		if isJoint && !exportJoints.keep(dag.Name) {
+			droppedJoints[dag.Name] = true
+			if parent.joint != nil {
+				jointNodes[dag.Name] = *parent.joint
+			}
+			offset := localMatrix(node)
+			parent.offset = &offset
+			for _, child := range dag.Children {
+				addDag(child, parent)
+			}
+			return
+		}
+		nodeID := uint32(len(doc.Nodes))
+		doc.Nodes = append(doc.Nodes, node)
+		nodeMap[dag.Name] = nodeID
+		if parent.offset != nil {
+			nodeOffsets[nodeID] = *parent.offset
+		}
+		if parent.node != nil {
+			parent.node.Children = append(parent.node.Children, nodeID)
+		} else {
+			scene.Nodes = append(scene.Nodes, nodeID)
+		}
+		children := dagParent{node: node, joint: parent.joint}
+		if isJoint {
+			root := parent.root
+			if root == nil {
+				root = &skinRoot{node: nodeID}
+				skinRoots = append(skinRoots, root)
+			}
+			root.joints = append(root.joints, nodeID)
+			jointNodes[dag.Name] = nodeID
+			children.root, children.joint = root, gltf.Index(nodeID)
+			jointChildren[dag.Name] = children
+		}
+		if attachment := findAttachment(dmxDag); attachment != nil {
+			setExtra(node, "isRigid", attachment.IsRigid)
+			setExtra(node, "isWorldAligned", attachment.IsWorldAligned)
+		}
+		if dmxMesh := findMesh(dmxDag); dmxMesh != nil {
+			meshNodes = append(meshNodes, meshNode{dmxMesh, nodeID})
+		}
+		for _, child := range dag.Children {
+			addDag(child, children)
+		}
+	}
+	// A model has no node of its own; its transform is folded into its
+	// children, as the bind matrices include it.
+	addModel := func(model *dmx.DmeModel) {
+		var parent dagParent
+		if m := model.Transform.Matrix(); m != dmxmath.Mat4Identity() {
+			offset := coords.matrix(m)
+			parent.offset = &offset
+		}
+		for _, child := range model.Children {
+			addDag(child, parent)
+		}
+	}
+	if dmxElement.Skeleton != nil {
+		addModel(dmxElement.Skeleton)
+	}
+	if dmxElement.Model != nil && dmxElement.Model != dmxElement.Skeleton {
+		addModel(dmxElement.Model)
+	}
 
 	// Find skins
-	for _, dmxChild := range dmxElement.Skeleton.Children {
-		var joints []uint32
-		if dmxJoint, ok := dmxChild.(*dmx.DmeJoint); ok {
-			var addJoint func(*dmx.DmeJoint) *uint32
-			addJoint = func(dmxJoint *dmx.DmeJoint) *uint32 {
-				if strings.Contains(dmxJoint.Name, "End") || strings.Contains(dmxJoint.Name, "parentConstraint") {
-					return nil
-				}
-				nodeID := uint32(len(doc.Nodes))
-				node := &gltf.Node{
-					Name:        dmxJoint.Name,
-					Translation: mulGlobalScale(dmxJoint.Transform.Position),
-					Rotation:    dmxJoint.Transform.Orientation,
-				}
-				jointMap[dmxJoint.Name] = nodeID
-				joints = append(joints, nodeID)
-				doc.Nodes = append(doc.Nodes, node)
-				for _, child := range dmxJoint.Children {
-					if child, ok := child.(*dmx.DmeJoint); ok {
-						childID := addJoint(child)
-						if childID != nil {
-							node.Children = append(node.Children, *childID)
-						}
-
-					}
-				}
-				return &nodeID
+	binds, bindState := bindMatrices(dmxElement.Skeleton)
+	inverseBinds := make(map[uint32][]dmxmath.Mat4)
+	addSkin := func(root *uint32, joints []uint32) uint32 {
+		skinID := uint32(len(doc.Skins))
+		skin := &gltf.Skin{
+			Name:     "Armature",
+			Skeleton: root,
+			Joints:   joints,
+		}
+		inverseBinds[skinID] = writeInverseBindMatrices(doc, skin, binds)
+		doc.Skins = append(doc.Skins, skin)
+		return skinID
+	}
+	rootSkins := make([]uint32, len(skinRoots))
+	for i, root := range skinRoots {
+		rootSkins[i] = addSkin(gltf.Index(root.node), root.joints)
+	}
+	// A mesh weighted to joints of several roots is bound to a skin
+	// holding every joint, which has no single skeleton root.
+	var sharedSkin *uint32
+	meshSkin := func(dmxVertexData *dmx.DmeVertexData) *uint32 {
+		used := weightedJoints(dmxVertexData, dmxElement.Model, jointNodes)
+		if len(used) == 0 {
+			return nil
+		}
+		for i, root := range skinRoots {
+			if coversJoints(root.joints, used) {
+				return &rootSkins[i]
+			}
+		}
+		if sharedSkin == nil {
+			var joints []uint32
+			for _, root := range skinRoots {
+				joints = append(joints, root.joints...)
 			}
-			rootBoneID := addJoint(dmxJoint)
-			scene.Nodes = append(scene.Nodes, *rootBoneID)
-			_skinID := uint32(len(doc.Skins))
-			skinID = &_skinID
-			doc.Skins = append(doc.Skins, &gltf.Skin{
-				Name:     "Armature",
-				Skeleton: rootBoneID,
-				Joints:   joints,
-			})
+			sharedSkin = gltf.Index(addSkin(nil, joints))
 		}
+		return sharedSkin
 	}
 
 	// Find meshes
-	var findMesh func([]dmx.IDag)
-	findMesh = func(dmxDags []dmx.IDag) {
-		for _, dmxDag := range dmxDags {
-			dmxDag, ok := dmxDag.(*dmx.DmeDag)
-			if !ok {
-				continue
+	var morphNodes []morphNode
+	addMesh := func(dmxMesh *dmx.DmeMesh, nodeID uint32) error {
+		node := doc.Nodes[nodeID]
+		meshName := strings.TrimSuffix(node.Name, "_mesh")
+		dmxVertexData := meshVertexData(dmxMesh, meshName, dmxElement.Model, pose)
+		mesh := &gltf.Mesh{Name: meshName}
+		positions := coords.points(dmxVertexData.Positions)
+		cornerPositions := dmxIndicesSort(dmxVertexData.PositionIndices, positions)
+		cornerNormals := dmxIndicesSort(dmxVertexData.NormalsIndices, coords.vectors(dmxVertexData.Normals))
+		cornerUVs := dmxUVToGLTFUV(dmxIndicesSort(dmxVertexData.TextureCoordinatesIndices, dmxVertexData.TextureCoordinates))
+		welder := newWelder(len(cornerPositions))
+		weldStream(welder, "positions", cornerPositions)
+		weldStream(welder, "normals", cornerNormals)
+		weldStream(welder, "texture coordinates", cornerUVs)
+		var cornerJoints [][][4]uint16
+		var cornerWeights [][][4]float32
+		var skinID *uint32
+		if isSkinned(dmxVertexData, dmxElement.Model) && pose == nil {
+			skinID = meshSkin(dmxVertexData)
+		}
+		if skinID != nil {
+			skin := doc.Skins[*skinID]
+			influences, err := skinInfluences(skin, dmxVertexData, dmxElement.Model, jointNodes)
+			if err != nil {
+				return fmt.Errorf("mesh \"%s\": %w", meshName, err)
 			}
-			meshName := strings.TrimSuffix(dmxDag.Name, "_mesh")
-			if dmxMesh := dmxDag.Mesh; dmxMesh != nil {
-				dmxVertexData := dmxMesh.CurrentState
-				mesh := &gltf.Mesh{Name: meshName}
-				attribute := gltf.Attribute{
-					"POSITION":   modeler.WritePosition(doc, dmxIndicesSort(dmxVertexData.PositionIndices, mulGlobalScale(dmxVertexData.Positions))),
-					"NORMAL":     modeler.WriteNormal(doc, dmxIndicesSort(dmxVertexData.NormalsIndices, dmxVertexData.Normals)),
-					"TEXCOORD_0": modeler.WriteTextureCoord(doc, dmxIndicesSort(dmxVertexData.TextureCoordinatesIndices, dmxUVToGLTFUV(dmxVertexData.TextureCoordinates))),
-				}
-				if len(dmxElement.Model.JointTransforms) > 0 {
-					jc := int(dmxVertexData.JointCount)
-					jointIndeices := make([][4]uint8, 0)
-					jointWeights := make([][4]float32, 0)
-					for i := range dmxVertexData.Positions {
-						var ji [4]uint8
-						var jw [4]float32
-						for j := 0; j < 4; j++ {
-							if j < jc {
-								_ji := dmxVertexData.JointIndices[i*jc+j]
-								ji[j] = uint8(jointMap[dmxElement.Model.JointTransforms[_ji].Name])
-								jw[j] = dmxVertexData.JointWeights[i*jc+j]
-							}
-						}
-						jointIndeices = append(jointIndeices, ji)
-						jointWeights = append(jointWeights, jw)
-					}
-					attribute["JOINTS_0"] = modeler.WriteJoints(doc, dmxIndicesSort(dmxVertexData.PositionIndices, jointIndeices))
-					attribute["WEIGHTS_0"] = modeler.WriteWeights(doc, dmxIndicesSort(dmxVertexData.PositionIndices, jointWeights))
+			cornerJoints, cornerWeights = influenceSets(dmxIndicesSort(dmxVertexData.PositionIndices, influences))
+			for set := range cornerJoints {
+				weldStream(welder, "joint indices", cornerJoints[set])
+				weldStream(welder, "joint weights", cornerWeights[set])
+			}
+			// Without a bind state the bind matrices come from the rest pose
+			// itself, which then has nothing to be checked against.
+			if bindState {
+				if d := checkRestPose(doc, skin, inverseBinds[*skinID], positions, dmxVertexData, dmxElement.Model, jointNodes); d > restTolerance {
+					log.Printf("⚠️ mesh \"%s\" moves up to %g units in the rest pose; the skeleton does not match its bind pose", meshName, d)
 				}
-				for _, dmxFaceSet := range dmxMesh.FaceSets {
-					primitive := &gltf.Primitive{
-						Attributes: attribute,
-						Indices: gltf.Index(
-							modeler.WriteIndices(
-								doc,
-								int32SliceTouint16Slice(
-									dmxFacesetToGLTFIndices(dmxFaceSet.Faces),
-								),
-							),
-						),
-						Material: getMaterialID(dmxFaceSet.Material.MtlName),
-					}
-					mesh.Primitives = append(mesh.Primitives, primitive)
+			}
+		}
+		var targetNames []string
+		var cornerTargets [][2][][3]float32
+		if pose == nil {
+			for _, delta := range dmxMesh.DeltaStates {
+				if delta == nil {
+					continue
 				}
-				scene.Nodes = append(scene.Nodes, uint32(len(doc.Nodes)))
-				node := &gltf.Node{
-					Name: meshName,
-					Mesh: gltf.Index(uint32(len(doc.Meshes))),
-					Skin: skinID,
+				positions, normals, err := cornerDeltas(delta, dmxVertexData, len(cornerNormals) > 0)
+				if err != nil {
+					return fmt.Errorf("mesh \"%s\": delta state \"%s\": %w", meshName, delta.Name, err)
 				}
-				doc.Nodes = append(doc.Nodes, node)
-				doc.Meshes = append(doc.Meshes, mesh)
+				weldStream(welder, "delta positions", positions)
+				weldStream(welder, "delta normals", normals)
+				targetNames = append(targetNames, delta.Name)
+				cornerTargets = append(cornerTargets, [2][][3]float32{positions, normals})
+			}
+		}
+		if welder.err != nil {
+			return fmt.Errorf("mesh \"%s\": %w", meshName, welder.err)
+		}
+		vertices := welder.corners()
+		attribute := gltf.Attribute{
+			"POSITION": modeler.WritePosition(doc, dmxIndicesSort(vertices, cornerPositions)),
+		}
+		if len(cornerNormals) > 0 {
+			attribute["NORMAL"] = modeler.WriteNormal(doc, dmxIndicesSort(vertices, cornerNormals))
+		}
+		if len(cornerUVs) > 0 {
+			attribute["TEXCOORD_0"] = modeler.WriteTextureCoord(doc, dmxIndicesSort(vertices, cornerUVs))
+		}
+		for set := range cornerJoints {
+			joints := gltfJoints(dmxIndicesSort(vertices, cornerJoints[set]), len(doc.Skins[*skinID].Joints))
+			attribute[fmt.Sprintf("JOINTS_%d", set)] = modeler.WriteJoints(doc, joints)
+			attribute[fmt.Sprintf("WEIGHTS_%d", set)] = modeler.WriteWeights(doc, dmxIndicesSort(vertices, cornerWeights[set]))
+		}
+		var targets []gltf.Attribute
+		for _, target := range cornerTargets {
+			var normals [][3]float32
+			if len(target[1]) > 0 {
+				normals = dmxIndicesSort(vertices, target[1])
 			}
-			findMesh(dmxDag.Dag().Children)
+			targets = append(targets, writeMorphTarget(doc, dmxIndicesSort(vertices, target[0]), normals))
+		}
+		if len(targets) > 0 {
+			mesh.Weights = make([]float32, len(targets))
+			mesh.Extras = map[string]any{"targetNames": targetNames}
+		}
+		for _, dmxFaceSet := range dmxMesh.FaceSets {
+			triangles := dmxFacesetToGLTFIndices(dmxFaceSet.Faces)
+			if coords.flipsWinding() {
+				flipTriangles(triangles)
+			}
+			remapped, err := welder.remap(triangles)
+			if err != nil {
+				return fmt.Errorf("mesh \"%s\": %w", meshName, err)
+			}
+			indices, err := gltfIndices(remapped, len(vertices))
+			if err != nil {
+				return fmt.Errorf("mesh \"%s\": %w", meshName, err)
+			}
+			primitive := &gltf.Primitive{
+				Attributes: attribute,
+				Indices:    gltf.Index(modeler.WriteIndices(doc, indices)),
+				Material:   getMaterialID(dmxFaceSet.Material.MtlName),
+				Targets:    targets,
+			}
+			mesh.Primitives = append(mesh.Primitives, primitive)
+		}
+		// Skinning to a pose leaves the positions in world space, so a mesh
+		// baked that way gets a node of its own at the root of the scene.
+		if isSkinned(dmxVertexData, dmxElement.Model) && pose != nil {
+			nodeID = uint32(len(doc.Nodes))
+			node = &gltf.Node{Name: node.Name}
+			doc.Nodes = append(doc.Nodes, node)
+			scene.Nodes = append(scene.Nodes, nodeID)
+		}
+		node.Mesh = gltf.Index(uint32(len(doc.Meshes)))
+		if len(cornerJoints) > 0 {
+			node.Skin = skinID
+		}
+		if len(targets) > 0 {
+			morphNodes = append(morphNodes, morphNode{nodeID, targetNames})
+		}
+		doc.Meshes = append(doc.Meshes, mesh)
+		return nil
+	} // addMesh
+	for _, m := range meshNodes {
+		if err := addMesh(m.mesh, m.node); err != nil {
+			return nil, err
 		}
-	} // findMesh
-	if dmxElement.Model != nil {
-		findMesh(dmxElement.Model.Children)
 	}
 
 	// Find animations
-	if dmxElement.AnimationList != nil {
+	if dmxElement.AnimationList != nil && pose == nil {
+		var combinationOperator *dmx.DmeCombinationOperator
+		if dmxElement.ModelRoot != nil {
+			combinationOperator = dmxElement.ModelRoot.CombinationOperator
+		}
 		for _, dmxAnimation := range dmxElement.AnimationList.Animations {
+			if rate := *argFPS; rate != 0 {
+				if rate < 0 {
+					rate = float64(dmxAnimation.FrameRate)
+				}
+				if rate > 0 {
+					dmxAnimation = dmxAnimation.Resample(rate)
+				}
+			}
 			animation := &gltf.Animation{
 				Name: title,
 			}
+			warned := make(map[string]bool)
 			for _, dmxChannel := range dmxAnimation.Channels {
 				samplerID := uint32(len(animation.Samplers))
 				sampler := &gltf.AnimationSampler{
 					Interpolation: gltf.InterpolationLinear,
 				}
-				joint, found := jointMap[dmxChannel.ToElement.Name]
+				dmxTransform := dmxChannel.Transform()
+				if dmxTransform == nil {
+					continue
+				}
+				joint, found := nodeMap[dmxTransform.Name]
 				if !found {
+					// The offset a dropped joint leaves in its children is its
+					// rest transform, so its animation cannot be kept.
+					if droppedJoints[dmxTransform.Name] && !warned[dmxTransform.Name] {
+						warned[dmxTransform.Name] = true
+						log.Printf("⚠️ joint \"%s\" is excluded but animated; its animation is lost", dmxTransform.Name)
+					}
 					continue
 				}
+				offset, hasOffset := nodeOffsets[joint]
 				channel := &gltf.Channel{
 					Sampler: gltf.Index(samplerID),
 					Target: gltf.ChannelTarget{
@@ -165,33 +373,43 @@ func convertModel(title string, dmxElement *dmx.DmElement) (*gltf.Document, erro
 					},
 				}
 
-				writeInput := func(input []int32) {
-					times := mulTimes(input)
-					sampler.Input = modeler.WriteAccessor(
-						doc,
-						gltf.TargetNone,
-						times,
-					)
-					accessor := doc.Accessors[int(sampler.Input)]
-					accessor.Min = []float32{lo.Min(times)}
-					accessor.Max = []float32{lo.Max(times)}
+				writeInput := func(input []dmx.Time) {
+					sampler.Input = writeTimes(doc, dmxAnimation, input)
 				}
 
-				if dmxChannel.LogVector3 != nil {
+				if dmxChannel.IsPosition() && dmxChannel.LogVector3 != nil {
 					channel.Target.Path = gltf.TRSTranslation
-					writeInput(dmxChannel.LogVector3.Layers[0].Times)
-					sampler.Output = modeler.WritePosition(
-						doc,
-						mulGlobalScale(dmxChannel.LogVector3.Layers[0].Values),
-					)
-				} else if dmxChannel.LogQuaternion != nil {
+					layer := dmxChannel.LogVector3.Layers[0].Trim(dmxAnimation.LogTime(0))
+					if *argReduceTranslation > 0 {
+						reduced := layer.Reduce(*argReduceTranslation / float64(coords.scale))
+						logReduced(dmxChannel, len(reduced.Times), len(layer.Times))
+						layer = reduced
+					}
+					writeInput(layer.Times)
+					values := coords.points(layer.Values)
+					if hasOffset {
+						for i, v := range values {
+							values[i] = offset.TransformPoint(v)
+						}
+					}
+					sampler.Output = modeler.WritePosition(doc, values)
+				} else if dmxChannel.IsOrientation() && dmxChannel.LogQuaternion != nil {
 					channel.Target.Path = gltf.TRSRotation
-					writeInput(dmxChannel.LogQuaternion.Layers[0].Times)
-					sampler.Output = modeler.WriteAccessor(
-						doc,
-						gltf.TargetNone,
-						dmxChannel.LogQuaternion.Layers[0].Values,
-					)
+					layer := dmxChannel.LogQuaternion.Layers[0].Trim(dmxAnimation.LogTime(0))
+					if *argReduceRotation > 0 {
+						reduced := layer.Reduce(*argReduceRotation)
+						logReduced(dmxChannel, len(reduced.Times), len(layer.Times))
+						layer = reduced
+					}
+					writeInput(layer.Times)
+					values := coords.quats(layer.Values)
+					if hasOffset {
+						_, rotation, _ := offset.Decompose()
+						for i, v := range values {
+							values[i] = rotation.Mul(v)
+						}
+					}
+					sampler.Output = modeler.WriteAccessor(doc, gltf.TargetNone, values)
 				} else {
 					continue
 				}
@@ -200,12 +418,84 @@ func convertModel(title string, dmxElement *dmx.DmElement) (*gltf.Document, erro
 				animation.Samplers = append(animation.Samplers, sampler)
 				animation.Channels = append(animation.Channels, channel)
 			}
+			writeFlexAnimation(doc, animation, dmxAnimation, combinationOperator, morphNodes)
 			doc.Animations = append(doc.Animations, animation)
 		}
 	}
 	return doc, nil
 }
 
+// snapshotPose returns the pose to bake meshes in as requested with -pose:
+// the first clip of the file at that time, or the rest pose if the file has
+// no animation. It returns nil when -pose is not set.
+func snapshotPose(dmxElement *dmx.DmElement) *dmx.Pose {
+	if *argPose < 0 || dmxElement.Skeleton == nil {
+		return nil
+	}
+	var clip *dmx.DmeChannelsClip
+	if dmxElement.AnimationList != nil && len(dmxElement.AnimationList.Animations) > 0 {
+		clip = dmxElement.AnimationList.Animations[0]
+	}
+	return dmx.EvaluatePose(dmxElement.Skeleton, clip, dmx.TimeFromSeconds(*argPose))
+}
+
+// meshVertexData returns the vertex data of dmxMesh to export: the base
+// state chosen with -state, skinned to pose unless pose is nil.
+func meshVertexData(dmxMesh *dmx.DmeMesh, meshName string, model *dmx.DmeModel, pose *dmx.Pose) *dmx.DmeVertexData {
+	dmxVertexData := dmxMesh.CurrentState
+	if *argState != "" {
+		if state := dmxMesh.BaseState(*argState); state != nil {
+			dmxVertexData = state
+		} else {
+			log.Printf("⚠️ mesh \"%s\" has no base state \"%s\", using currentState", meshName, *argState)
+		}
+	}
+	if pose != nil && model != nil && len(model.JointTransforms) > 0 {
+		dmxVertexData = dmxVertexData.Skin(dmx.SkinMatrices(model, pose))
+	}
+	return dmxVertexData
+}
+
+// findMesh returns the mesh dag is or holds as its shape.
+func findMesh(dag dmx.IDag) *dmx.DmeMesh {
+	if mesh, ok := dag.(*dmx.DmeMesh); ok {
+		return mesh
+	}
+	return dag.Dag().Mesh
+}
+
+// findAttachment returns the attachment dag is or holds as its shape.
+func findAttachment(dag dmx.IDag) *dmx.DmeAttachment {
+	if attachment, ok := dag.(*dmx.DmeAttachment); ok {
+		return attachment
+	}
+	return dag.Dag().Attachment
+}
+
+// dagNode returns a node placed like dag. A hidden dag is marked in the
+// node's extras, as glTF has no visibility.
+func dagNode(dag *dmx.DmeDag) *gltf.Node {
+	node := &gltf.Node{Name: dag.Name}
+	if dag.Transform != nil {
+		node.Translation = coords.point(dag.Transform.Position)
+		node.Rotation = coords.quat(dag.Transform.Orientation)
+		node.Scale = coords.scaleVector(dag.Transform.Scale)
+	}
+	if !dag.Visible {
+		setExtra(node, "visible", false)
+	}
+	return node
+}
+
+func setExtra(node *gltf.Node, key string, value any) {
+	extras, ok := node.Extras.(map[string]any)
+	if !ok {
+		extras = make(map[string]any)
+		node.Extras = extras
+	}
+	extras[key] = value
+}
+
 func dmxFacesetToGLTFIndices(faceset []int32) []int32 {
 	result := make([]int32, 0, len(faceset))
 	first := int32(-1)
@@ -245,64 +535,58 @@ func dmxIndicesSort[T any](indices []int32, value []T) []T {
 	return result
 }
 
-func int32SliceTouint16Slice(values []int32) []uint16 {
-	result := make([]uint16, len(values))
-	for i, v := range values {
-		result[i] = uint16(v)
+// gltfIndices converts indices into a vertex buffer of vertexCount vertices
+// to uint16, or to uint32 when uint16 cannot address every vertex.
+func gltfIndices(indices []int32, vertexCount int) (any, error) {
+	if uint64(vertexCount) > math.MaxUint32 {
+		return nil, fmt.Errorf("%d vertices do not fit in 32-bit indices", vertexCount)
 	}
-	return result
-}
-
-type GlobalScaler interface {
-	[][3]float32 | [3]float32
-}
-
-func mulGlobalScale[T GlobalScaler](values T) T {
-	switch values := any(values).(type) {
-	case [][3]float32:
-		for i := range values {
-			values[i][0] *= float32(*argScale)
-			values[i][1] *= float32(*argScale)
-			values[i][2] *= float32(*argScale)
-		}
-		return any(values).(T)
-	case [3]float32:
-		values[0] *= float32(*argScale)
-		values[1] *= float32(*argScale)
-		values[2] *= float32(*argScale)
-		return any(values).(T)
-	default:
-		panic("unreachable")
+	for _, v := range indices {
+		if v < 0 || int(v) >= vertexCount {
+			return nil, fmt.Errorf("index %d out of range of %d vertices", v, vertexCount)
+		}
+	}
+	// The largest value of each index type is reserved for primitive
+	// restart, so uint16 indices can address 65535 vertices.
+	if vertexCount <= math.MaxUint16 {
+		result := make([]uint16, len(indices))
+		for i, v := range indices {
+			result[i] = uint16(v)
+		}
+		return result, nil
 	}
+	result := make([]uint32, len(indices))
+	for i, v := range indices {
+		result[i] = uint32(v)
+	}
+	return result, nil
 }
 
-func mulTimes(s []int32) []float32 {
-	result := make([]float32, len(s))
-	for i, v := range s {
-		result[i] = float32(v) * 0.0001
-	}
-	return result
+func logReduced(channel *dmx.DmeChannel, kept, total int) {
+	log.Printf("ℹ️  %s: kept %d of %d keys", channel.Name, kept, total)
 }
 
-/*
-func makeMatrix(t *dmx.DmeTransform) [16]float32 {
-	pos := t.Position
-	quat := t.Orientation
-	qx, qy, qz, qw := quat[0], quat[1], quat[2], quat[3]
-	return [16]float32{
-		1 - 2*qy*qy - 2*qz*qz, 2*qx*qy - 2*qz*qw, 2*qx*qz + 2*qy*qw, 0,
-		2*qx*qy + 2*qz*qw, 1 - 2*qx*qx - 2*qz*qz, 2*qy*qz - 2*qx*qw, 0,
-		2*qx*qz - 2*qy*qw, 2*qy*qz + 2*qx*qw, 1 - 2*qx*qx - 2*qy*qy, 0,
-		pos[0], pos[1], pos[2], 1,
-	}
+// writeTimes writes log times as an animation input accessor in seconds
+// since the start of the clip.
+func writeTimes(doc *gltf.Document, clip *dmx.DmeChannelsClip, input []dmx.Time) uint32 {
+	times := clipSeconds(clip, input)
+	index := modeler.WriteAccessor(doc, gltf.TargetNone, times)
+	accessor := doc.Accessors[index]
+	accessor.Min = []float32{lo.Min(times)}
+	accessor.Max = []float32{lo.Max(times)}
+	return index
 }
 
-func scaleMatrix(m [16]float32, scale float32) [16]float32 {
-	for i := 0; i < 3; i++ {
-		m[i*4+0] *= scale
-		m[i*4+1] *= scale
-		m[i*4+2] *= scale
+// clipSeconds converts log times to seconds since the start of the clip.
+// glTF animations cannot start before zero, so times before the start of
+// the clip become zero; layers are trimmed to the clip beforehand so that
+// this does not repeat a time.
+func clipSeconds(clip *dmx.DmeChannelsClip, times []dmx.Time) []float32 {
+	result := make([]float32, len(times))
+	for i, t := range times {
+		if local := clip.LocalTime(t); local > 0 {
+			result[i] = float32(local.Seconds())
+		}
 	}
-	return m
+	return result
 }
-*/
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aoisensi/darkseer/dmx"
+)
+
+func TestClipSeconds(t *testing.T) {
+	clip := &dmx.DmeChannelsClip{
+		TimeFrame: &dmx.DmeTimeFrame{StartTime: 20000, OffsetTime: 5000, Scale: 2},
+	}
+	// Log time 5000 is the start of the clip; log times advance twice as
+	// fast as clip time.
+	got := clipSeconds(clip, []dmx.Time{1000, 5000, 15000, 25000})
+	want := []float32{0, 0, 0.5, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("clipSeconds()[%d] = %g, want %g", i, got[i], want[i])
+		}
+	}
+	for i, s := range got {
+		if s < 0 {
+			t.Errorf("clipSeconds()[%d] = %g is before the start of the clip", i, s)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aoisensi/darkseer/dmx/dmxmath"
+)
+
+// coordSystem converts from Source coordinates, which are Z up, X forward,
+// right-handed and in inches, to the output convention.
+type coordSystem struct {
+	// basis takes the Source axes to the output axes. It is a reflection
+	// when the handedness changes.
+	basis dmxmath.Mat4
+	// rotation conjugates rotations the way basis does. A reflection
+	// conjugates like the rotation it is the negation of.
+	rotation dmxmath.Quat
+	scale    float32
+}
+
+// coords is the output convention chosen with -up, -forward, -handedness
+// and -scale.
+var coords = coordSystem{
+	basis:    dmxmath.Mat4Identity(),
+	rotation: dmxmath.QuatIdentity(),
+	scale:    0.02,
+}
+
+// newCoordSystem returns the conversion to a system whose up and forward
+// axes are given as x, y or z with an optional sign, and whose handedness is
+// right or left.
+func newCoordSystem(up, forward, handedness string, scale float64) (coordSystem, error) {
+	u, err := parseAxis(up)
+	if err != nil {
+		return coordSystem{}, err
+	}
+	f, err := parseAxis(forward)
+	if err != nil {
+		return coordSystem{}, err
+	}
+	if u.Cross(f) == (dmxmath.Vec3{}) {
+		return coordSystem{}, fmt.Errorf("up axis %s and forward axis %s are not perpendicular", up, forward)
+	}
+	// Source's left axis, Y, is up × forward.
+	l := u.Cross(f)
+	switch handedness {
+	case "right":
+	case "left":
+		l = l.Neg()
+	default:
+		return coordSystem{}, fmt.Errorf("unknown handedness \"%s\"", handedness)
+	}
+	basis := dmxmath.Mat4Identity()
+	for i, axis := range [3]dmxmath.Vec3{f, l, u} {
+		basis[i*4], basis[i*4+1], basis[i*4+2] = axis[0], axis[1], axis[2]
+	}
+	proper := basis
+	if basis.Det() < 0 {
+		for i := 0; i < 12; i++ {
+			proper[i] = -proper[i]
+		}
+	}
+	return coordSystem{
+		basis:    basis,
+		rotation: dmxmath.QuatFromMat4(proper),
+		scale:    float32(scale),
+	}, nil
+}
+
+func parseAxis(s string) (dmxmath.Vec3, error) {
+	sign := float32(1)
+	name := strings.TrimPrefix(s, "+")
+	if strings.HasPrefix(name, "-") {
+		sign, name = -1, name[1:]
+	}
+	var axis dmxmath.Vec3
+	switch strings.ToLower(name) {
+	case "x":
+		axis[0] = sign
+	case "y":
+		axis[1] = sign
+	case "z":
+		axis[2] = sign
+	default:
+		return axis, fmt.Errorf("unknown axis \"%s\"", s)
+	}
+	return axis, nil
+}
+
+// point converts a position.
+func (c coordSystem) point(p [3]float32) [3]float32 {
+	return c.basis.TransformVector(p).Scale(c.scale)
+}
+
+// points converts positions into a new slice.
+func (c coordSystem) points(ps [][3]float32) [][3]float32 {
+	result := make([][3]float32, len(ps))
+	for i, p := range ps {
+		result[i] = c.point(p)
+	}
+	return result
+}
+
+// vector converts a direction such as a normal or a tangent, which is not
+// scaled.
+func (c coordSystem) vector(v [3]float32) [3]float32 {
+	return c.basis.TransformVector(v)
+}
+
+// vectors converts directions into a new slice.
+func (c coordSystem) vectors(vs [][3]float32) [][3]float32 {
+	result := make([][3]float32, len(vs))
+	for i, v := range vs {
+		result[i] = c.vector(v)
+	}
+	return result
+}
+
+// quat converts an orientation.
+func (c coordSystem) quat(q [4]float32) [4]float32 {
+	return c.rotation.Mul(q).Mul(c.rotation.Conjugate())
+}
+
+// quats converts orientations into a new slice.
+func (c coordSystem) quats(qs [][4]float32) [][4]float32 {
+	result := make([][4]float32, len(qs))
+	for i, q := range qs {
+		result[i] = c.quat(q)
+	}
+	return result
+}
+
+// matrix converts a transform.
+func (c coordSystem) matrix(m dmxmath.Mat4) dmxmath.Mat4 {
+	m = c.basis.Mul(m).Mul(c.basis.Transpose())
+	m[12], m[13], m[14] = m[12]*c.scale, m[13]*c.scale, m[14]*c.scale
+	return m
+}
+
+// flipsWinding reports whether the conversion mirrors geometry, which
+// turns counter-clockwise triangles clockwise.
+func (c coordSystem) flipsWinding() bool {
+	return c.basis.Det() < 0
+}
+
+// scaleVector converts per-axis scale factors. The output axes are the
+// Source axes permuted, so the factors are permuted alike.
+func (c coordSystem) scaleVector(s [3]float32) [3]float32 {
+	v := c.basis.TransformVector(s)
+	for i := range v {
+		if v[i] < 0 {
+			v[i] = -v[i]
+		}
+	}
+	return v
+}
+
+// flipTriangles reverses the winding of triangle list indices in place.
+func flipTriangles(indices []int32) {
+	for i := 0; i+2 < len(indices); i += 3 {
+		indices[i+1], indices[i+2] = indices[i+2], indices[i+1]
+	}
+}
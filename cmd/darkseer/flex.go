@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/aoisensi/darkseer/dmx"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// morphNode is a node whose mesh has morph targets, named after the delta
+// states they were made from.
+type morphNode struct {
+	node        uint32
+	targetNames []string
+}
+
+// flexChannel is a float log driving the value of a flex control.
+type flexChannel struct {
+	control string
+	log     *dmx.DmeLog[float32]
+}
+
+// flexChannels returns the channels of clip that drive flex controls. Flex
+// weight arrays are indexed by the controls of op.
+func flexChannels(clip *dmx.DmeChannelsClip, op *dmx.DmeCombinationOperator) []flexChannel {
+	var result []flexChannel
+	for _, channel := range clip.Channels {
+		if channel == nil || channel.LogFloat == nil {
+			continue
+		}
+		if control, ok := channel.Control(); ok && channel.ToAttribute == "value" {
+			result = append(result, flexChannel{control, channel.LogFloat})
+			continue
+		}
+		if index, ok := channel.FlexWeightIndex(); ok && op != nil && index < len(op.Controls) && op.Controls[index] != nil {
+			result = append(result, flexChannel{op.Controls[index].Name, channel.LogFloat})
+		}
+	}
+	return result
+}
+
+// writeFlexAnimation adds a weights channel to animation for every node
+// whose morph targets the flex channels of clip drive. The weights are
+// sampled at every key time of those channels.
+func writeFlexAnimation(doc *gltf.Document, animation *gltf.Animation, clip *dmx.DmeChannelsClip, op *dmx.DmeCombinationOperator, nodes []morphNode) {
+	channels := flexChannels(clip, op)
+	if len(channels) == 0 || len(nodes) == 0 {
+		return
+	}
+	// Keys before the start of the clip are sampled at its start instead.
+	start := clip.LogTime(0)
+	seen := make(map[dmx.Time]bool)
+	var times []dmx.Time
+	for _, channel := range channels {
+		for _, layer := range channel.log.Layers {
+			for _, t := range layer.Times {
+				if t < start {
+					t = start
+				}
+				if !seen[t] {
+					seen[t] = true
+					times = append(times, t)
+				}
+			}
+		}
+	}
+	if len(times) == 0 {
+		return
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	values := make([]map[string]float32, len(times))
+	for i, t := range times {
+		values[i] = make(map[string]float32, len(channels))
+		for _, channel := range channels {
+			values[i][channel.control] = channel.log.Value(t)
+		}
+	}
+	input := writeTimes(doc, clip, times)
+	for _, node := range nodes {
+		weights := make([]float32, 0, len(times)*len(node.targetNames))
+		for i := range times {
+			weights = append(weights, deltaWeights(op, values[i], node.targetNames)...)
+		}
+		samplerID := uint32(len(animation.Samplers))
+		animation.Samplers = append(animation.Samplers, &gltf.AnimationSampler{
+			Input:         input,
+			Output:        modeler.WriteAccessor(doc, gltf.TargetNone, weights),
+			Interpolation: gltf.InterpolationLinear,
+		})
+		animation.Channels = append(animation.Channels, &gltf.Channel{
+			Sampler: gltf.Index(samplerID),
+			Target: gltf.ChannelTarget{
+				Node: gltf.Index(node.node),
+				Path: gltf.TRSWeights,
+			},
+		})
+	}
+}
+
+// deltaWeights maps control values to the weights of the delta states named
+// in targetNames through op. Without a combination operator every control
+// drives the delta state of the same name.
+func deltaWeights(op *dmx.DmeCombinationOperator, values map[string]float32, targetNames []string) []float32 {
+	if op != nil {
+		return op.DeltaWeights(values, targetNames)
+	}
+	result := make([]float32, len(targetNames))
+	for i, name := range targetNames {
+		result[i] = values[name]
+	}
+	return result
+}
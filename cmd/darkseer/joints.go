@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// jointFilter decides which joints are exported. The zero value keeps every
+// joint.
+type jointFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	list    map[string]bool
+}
+
+// exportJoints is the filter built from -include-joints, -exclude-joints
+// and -joint-list.
+var exportJoints = &jointFilter{}
+
+// newJointFilter builds a filter from the joint flags.
+func newJointFilter() (*jointFilter, error) {
+	f := &jointFilter{}
+	var err error
+	if *argIncludeJoints != "" {
+		if f.include, err = regexp.Compile(*argIncludeJoints); err != nil {
+			return nil, err
+		}
+	}
+	if *argExcludeJoints != "" {
+		if f.exclude, err = regexp.Compile(*argExcludeJoints); err != nil {
+			return nil, err
+		}
+	}
+	if *argJointList != "" {
+		if f.list, err = readJointList(*argJointList); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// readJointList reads joint names, one per line. Blank lines and lines
+// starting with # are skipped.
+func readJointList(name string) (map[string]bool, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	result := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result[line] = true
+	}
+	return result, scanner.Err()
+}
+
+// keep reports whether the joint named name is exported.
+func (f *jointFilter) keep(name string) bool {
+	if f.list != nil && !f.list[name] {
+		return false
+	}
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	return f.exclude == nil || !f.exclude.MatchString(name)
+}
@@ -12,10 +12,42 @@ import (
 	"github.com/samber/lo"
 )
 
-var argScale = flag.Float64("scale", 0.02, "scale factor")
+var (
+	argScale = flag.Float64("scale", 0.02, "output units per inch")
+	argState = flag.String("state", "", "name of the base state to export (e.g. bind); empty uses currentState")
+	argFPS   = flag.Float64("fps", 0, "resample animations to this frame rate; 0 keeps the original keys, negative uses each clip's frame rate")
+
+	argPose   = flag.Float64("pose", -1, "pose the joints as the first clip does at this time in seconds (the rest pose if there is no clip) and bake meshes in that pose, unskinned; negative disables")
+	argFormat = flag.String("format", "gltf", "output format: gltf or obj")
+
+	argUp         = flag.String("up", "z", "output axis Source's up axis maps to: x, y or z, optionally signed (e.g. y, -z)")
+	argForward    = flag.String("forward", "x", "output axis Source's forward axis maps to: x, y or z, optionally signed")
+	argHandedness = flag.String("handedness", "right", "handedness of the output coordinate system: right or left")
+
+	argIncludeJoints = flag.String("include-joints", "", "export only joints whose name matches this regular expression")
+	argExcludeJoints = flag.String("exclude-joints", "", "drop joints whose name matches this regular expression")
+	argJointList     = flag.String("joint-list", "", "export only the joints named in this file, one per line")
+
+	argLimitInfluences = flag.Bool("limit-influences", false, "keep only the four strongest joint influences per vertex instead of writing JOINTS_1/WEIGHTS_1 and beyond")
+
+	argReduceTranslation = flag.Float64("reduce-translation", 0, "drop translation keys reproduced within this distance in output units; 0 keeps every key")
+	argReduceRotation    = flag.Float64("reduce-rotation", 0, "drop rotation keys reproduced within this angle in degrees; 0 keeps every key")
+)
 
 func main() {
 	flag.Parse()
+	if *argFormat != "gltf" && *argFormat != "obj" {
+		log.Fatalf("❌ unknown format \"%s\"", *argFormat)
+	}
+	filter, err := newJointFilter()
+	if err != nil {
+		log.Fatalln("❌", err)
+	}
+	exportJoints = filter
+	coords, err = newCoordSystem(*argUp, *argForward, *argHandedness, *argScale)
+	if err != nil {
+		log.Fatalln("❌", err)
+	}
 	for _, arg := range flag.Args() {
 		for _, name := range lo.Must(filepath.Glob(arg)) {
 			if !strings.HasSuffix(name, ".dmx") {
@@ -36,6 +68,15 @@ func main() {
 			}
 			noext := strings.TrimSuffix(name, ".dmx")
 
+			if *argFormat == "obj" {
+				nameOBJ := noext + ".obj"
+				if err := saveOBJ(element, nameOBJ); err != nil {
+					log.Println("❌", err)
+					continue
+				}
+				log.Println("✅ saved", nameOBJ)
+				continue
+			}
 			doc, err := convertModel(filepath.Base(noext), element)
 			if err != nil {
 				log.Println("❌", err)
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aoisensi/darkseer/dmx"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// cornerDeltas returns the offsets delta moves every face corner of base by,
+// looked up through the corner's position and normal indices. Positions
+// are in output units. Normal offsets are only returned if withNormals is
+// set, for meshes that export normals.
+func cornerDeltas(delta *dmx.DmeVertexDeltaData, base *dmx.DmeVertexData, withNormals bool) (positions, normals [][3]float32, err error) {
+	positionDeltas, err := indexedDeltas("positions", delta.Positions, delta.PositionIndices, len(base.Positions))
+	if err != nil {
+		return nil, nil, err
+	}
+	positions = dmxIndicesSort(base.PositionIndices, coords.points(positionDeltas))
+	if withNormals {
+		normalDeltas, err := indexedDeltas("normals", delta.Normals, delta.NormalsIndices, len(base.Normals))
+		if err != nil {
+			return nil, nil, err
+		}
+		normals = dmxIndicesSort(base.NormalsIndices, coords.vectors(normalDeltas))
+	}
+	return positions, normals, nil
+}
+
+// indexedDeltas spreads deltas over count base values, the ones indices
+// name, leaving the rest zero.
+func indexedDeltas(name string, deltas [][3]float32, indices []int32, count int) ([][3]float32, error) {
+	if len(indices) != len(deltas) {
+		return nil, fmt.Errorf("%d delta %s have %d indices", len(deltas), name, len(indices))
+	}
+	result := make([][3]float32, count)
+	for i, index := range indices {
+		if index < 0 || int(index) >= count {
+			return nil, fmt.Errorf("delta %s index %d out of range of %d", name, index, count)
+		}
+		result[index] = deltas[i]
+	}
+	return result, nil
+}
+
+// writeMorphTarget writes the position and normal offsets of a morph target
+// and returns its attributes. Normals are left out if there are none.
+func writeMorphTarget(doc *gltf.Document, positions, normals [][3]float32) gltf.Attribute {
+	position := writeDeltas(doc, positions)
+	accessor := doc.Accessors[position]
+	min, max := deltaBounds(positions)
+	accessor.Min, accessor.Max = min[:], max[:]
+	target := gltf.Attribute{"POSITION": position}
+	if len(normals) > 0 {
+		target["NORMAL"] = writeDeltas(doc, normals)
+	}
+	return target
+}
+
+// writeDeltas writes deltas as a float VEC3 accessor. Only the non-zero
+// deltas are stored, in a sparse accessor, when that takes less space.
+func writeDeltas(doc *gltf.Document, deltas [][3]float32) uint32 {
+	var indices []int32
+	var values [][3]float32
+	for i, delta := range deltas {
+		if delta != [3]float32{} {
+			indices = append(indices, int32(i))
+			values = append(values, delta)
+		}
+	}
+	sparseIndices, err := gltfIndices(indices, len(deltas))
+	indexSize := 2
+	if _, ok := sparseIndices.([]uint32); ok {
+		indexSize = 4
+	}
+	if err != nil || len(values)*(12+indexSize) >= len(deltas)*12 {
+		return modeler.WriteAccessor(doc, gltf.TargetArrayBuffer, deltas)
+	}
+	accessor := &gltf.Accessor{
+		ComponentType: gltf.ComponentFloat,
+		Type:          gltf.AccessorVec3,
+		Count:         uint32(len(deltas)),
+	}
+	// An accessor without a buffer view is all zeros, which is what an
+	// untouched vertex needs.
+	if len(values) > 0 {
+		padBuffer(doc)
+		valuesView := modeler.WriteBufferView(doc, gltf.TargetNone, values)
+		indicesView := modeler.WriteBufferView(doc, gltf.TargetNone, sparseIndices)
+		componentType := gltf.ComponentUshort
+		if indexSize == 4 {
+			componentType = gltf.ComponentUint
+		}
+		accessor.Sparse = &gltf.Sparse{
+			Count:   uint32(len(values)),
+			Indices: gltf.SparseIndices{BufferView: indicesView, ComponentType: componentType},
+			Values:  gltf.SparseValues{BufferView: valuesView},
+		}
+	}
+	doc.Accessors = append(doc.Accessors, accessor)
+	return uint32(len(doc.Accessors) - 1)
+}
+
+// padBuffer aligns the end of the last buffer of doc to four bytes so that
+// the next buffer view can hold floats.
+func padBuffer(doc *gltf.Document) {
+	if len(doc.Buffers) == 0 {
+		return
+	}
+	buffer := doc.Buffers[len(doc.Buffers)-1]
+	for len(buffer.Data)%4 != 0 {
+		buffer.Data = append(buffer.Data, 0)
+		buffer.ByteLength++
+	}
+}
+
+func deltaBounds(deltas [][3]float32) (min, max [3]float32) {
+	for i, delta := range deltas {
+		for j, v := range delta {
+			if i == 0 || v < min[j] {
+				min[j] = v
+			}
+			if i == 0 || v > max[j] {
+				max[j] = v
+			}
+		}
+	}
+	return min, max
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aoisensi/darkseer/dmx"
+	"github.com/aoisensi/darkseer/dmx/dmxmath"
+	"github.com/samber/lo"
+)
+
+// saveOBJ writes the meshes of dmxElement to a Wavefront OBJ file, posed as
+// requested with -pose.
+func saveOBJ(dmxElement *dmx.DmElement, name string) error {
+	if dmxElement.Model == nil {
+		return fmt.Errorf("no model in %s", dmxElement.Name)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	pose := snapshotPose(dmxElement)
+	var base [3]int // 1-based offsets of v, vt and vn
+	walkPosedDag(dmxElement.Model.Children, dmxElement.Model.Transform.Matrix(), pose, func(dag dmx.IDag, world dmxmath.Mat4) {
+		dmxMesh := findMesh(dag)
+		if dmxMesh == nil {
+			return
+		}
+		meshName := strings.TrimSuffix(dag.Dag().Name, "_mesh")
+		dmxVertexData := meshVertexData(dmxMesh, meshName, dmxElement.Model, pose)
+		// Skinned positions are in world space already, as in the glTF
+		// output, and skinning to a pose keeps them there.
+		if isSkinned(dmxVertexData, dmxElement.Model) {
+			world = dmxmath.Mat4Identity()
+		}
+		normalMatrix := world.Inverse().Transpose()
+		fmt.Fprintf(w, "o %s\n", meshName)
+		for _, p := range dmxVertexData.Positions {
+			p = coords.point(world.TransformPoint(p))
+			fmt.Fprintf(w, "v %g %g %g\n", p[0], p[1], p[2])
+		}
+		for _, uv := range dmxVertexData.TextureCoordinates {
+			fmt.Fprintf(w, "vt %g %g\n", uv[0], uv[1])
+		}
+		for _, n := range dmxVertexData.Normals {
+			n = coords.vector(normalMatrix.TransformVector(n).Normalize())
+			fmt.Fprintf(w, "vn %g %g %g\n", n[0], n[1], n[2])
+		}
+		corner := func(c int32) string {
+			result := fmt.Sprint(int(dmxVertexData.PositionIndices[c]) + base[0] + 1)
+			result += "/"
+			if int(c) < len(dmxVertexData.TextureCoordinatesIndices) {
+				result += fmt.Sprint(int(dmxVertexData.TextureCoordinatesIndices[c]) + base[1] + 1)
+			}
+			if int(c) < len(dmxVertexData.NormalsIndices) {
+				result += fmt.Sprintf("/%d", int(dmxVertexData.NormalsIndices[c])+base[2]+1)
+			}
+			return result
+		}
+		for _, dmxFaceSet := range dmxMesh.FaceSets {
+			fmt.Fprintf(w, "usemtl %s\n", lo.Must(lo.Last(strings.Split(dmxFaceSet.Material.MtlName, "/"))))
+			var face []string
+			for _, c := range dmxFaceSet.Faces {
+				if c == -1 {
+					if len(face) >= 3 {
+						if coords.flipsWinding() {
+							lo.Reverse(face)
+						}
+						fmt.Fprintf(w, "f %s\n", strings.Join(face, " "))
+					}
+					face = face[:0]
+					continue
+				}
+				face = append(face, corner(c))
+			}
+		}
+		base[0] += len(dmxVertexData.Positions)
+		base[1] += len(dmxVertexData.TextureCoordinates)
+		base[2] += len(dmxVertexData.Normals)
+	})
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// walkPosedDag is dmx.WalkDag with the world matrices of the joints pose
+// holds taken from it. A nil pose leaves every dag at rest.
+func walkPosedDag(children []dmx.IDag, parent dmxmath.Mat4, pose *dmx.Pose, fn func(dag dmx.IDag, world dmxmath.Mat4)) {
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		dag := child.Dag()
+		world := parent.Mul(dag.Transform.Matrix())
+		if _, isJoint := child.(*dmx.DmeJoint); isJoint && pose != nil {
+			if jointPose := pose.Joint(dag.Name); jointPose != nil {
+				world = jointPose.World
+			}
+		}
+		fn(child, world)
+		walkPosedDag(dag.Children, world, pose, fn)
+	}
+}
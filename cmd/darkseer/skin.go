@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/aoisensi/darkseer/dmx"
+	"github.com/aoisensi/darkseer/dmx/dmxmath"
+	"github.com/qmuntal/gltf"
+	"github.com/qmuntal/gltf/modeler"
+)
+
+// restTolerance is how far, in output units, the rest pose may move a
+// vertex before checkRestPose warns.
+const restTolerance = 1e-4
+
+// bindMatrices returns the world-space bind matrix of every joint of the
+// skeleton, keyed by joint name, in output coordinates. The matrices come
+// from the bind state of the skeleton, the pose its meshes were authored in,
+// and from its rest pose if it has none; fromBindState tells which.
+func bindMatrices(skeleton *dmx.DmeModel) (binds map[string]dmxmath.Mat4, fromBindState bool) {
+	pose := dmx.BindPose(skeleton)
+	fromBindState = pose != nil
+	if pose == nil {
+		pose = dmx.EvaluatePose(skeleton, nil, 0)
+	}
+	binds = make(map[string]dmxmath.Mat4)
+	for _, joint := range pose.Joints {
+		binds[joint.Joint.Name] = coords.matrix(joint.World)
+	}
+	return binds, fromBindState
+}
+
+// writeInverseBindMatrices writes the inverses of binds for the skin's
+// joints, looked up by node name, and returns them. Joints without a bind
+// matrix are bound where the node hierarchy puts them.
+func writeInverseBindMatrices(doc *gltf.Document, skin *gltf.Skin, binds map[string]dmxmath.Mat4) []dmxmath.Mat4 {
+	worlds := nodeWorldMatrices(doc)
+	inverses := make([]dmxmath.Mat4, len(skin.Joints))
+	matrices := make([][4][4]float32, len(skin.Joints))
+	for i, nodeID := range skin.Joints {
+		bind, ok := binds[doc.Nodes[nodeID].Name]
+		if !ok {
+			bind = worlds[nodeID]
+		}
+		inverses[i] = bind.Inverse()
+		// The accessor writer takes matrices row by row.
+		matrices[i] = inverses[i].VMatrix()
+	}
+	skin.InverseBindMatrices = gltf.Index(modeler.WriteAccessor(doc, gltf.TargetNone, matrices))
+	return inverses
+}
+
+// nodeWorldMatrices returns the world matrix of every node of doc.
+func nodeWorldMatrices(doc *gltf.Document) []dmxmath.Mat4 {
+	parents := make(map[uint32]uint32)
+	for i, node := range doc.Nodes {
+		for _, child := range node.Children {
+			parents[child] = uint32(i)
+		}
+	}
+	worlds := make([]dmxmath.Mat4, len(doc.Nodes))
+	done := make([]bool, len(doc.Nodes))
+	var world func(uint32) dmxmath.Mat4
+	world = func(i uint32) dmxmath.Mat4 {
+		if done[i] {
+			return worlds[i]
+		}
+		local := localMatrix(doc.Nodes[i])
+		if parent, ok := parents[i]; ok {
+			local = world(parent).Mul(local)
+		}
+		worlds[i], done[i] = local, true
+		return local
+	}
+	for i := range doc.Nodes {
+		world(uint32(i))
+	}
+	return worlds
+}
+
+// localMatrix returns the transform of node relative to its parent.
+func localMatrix(node *gltf.Node) dmxmath.Mat4 {
+	return dmxmath.Compose(node.TranslationOrDefault(), node.RotationOrDefault(), node.ScaleOrDefault())
+}
+
+// setLocalMatrix sets the translation, rotation and scale of node to m.
+func setLocalMatrix(node *gltf.Node, m dmxmath.Mat4) {
+	t, r, s := m.Decompose()
+	node.Translation, node.Rotation, node.Scale = t, r, s
+}
+
+// checkRestPose skins positions, which are in output units, with the skin's
+// joints at rest and returns the largest distance a vertex moved. It is zero
+// when the joint hierarchy written to doc matches the bind state the inverse
+// bind matrices were made from.
+func checkRestPose(doc *gltf.Document, skin *gltf.Skin, inverseBinds []dmxmath.Mat4, positions [][3]float32, dmxVertexData *dmx.DmeVertexData, model *dmx.DmeModel, jointMap map[string]uint32) float32 {
+	worlds := nodeWorldMatrices(doc)
+	skinning := make(map[uint32]dmxmath.Mat4)
+	for i, nodeID := range skin.Joints {
+		skinning[nodeID] = worlds[nodeID].Mul(inverseBinds[i])
+	}
+	jc := int(dmxVertexData.JointCount)
+	var worst float32
+	for i, p := range positions {
+		var m dmxmath.Mat4
+		var total float32
+		for j := 0; j < jc && i*jc+j < len(dmxVertexData.JointWeights) && i*jc+j < len(dmxVertexData.JointIndices); j++ {
+			w := dmxVertexData.JointWeights[i*jc+j]
+			ji := int(dmxVertexData.JointIndices[i*jc+j])
+			if w == 0 || ji < 0 || ji >= len(model.JointTransforms) {
+				continue
+			}
+			nodeID, ok := jointMap[model.JointTransforms[ji].Name]
+			if !ok {
+				continue
+			}
+			jointSkinning, ok := skinning[nodeID]
+			if !ok {
+				continue
+			}
+			m = m.Add(jointSkinning.Scale(w))
+			total += w
+		}
+		if total == 0 {
+			continue
+		}
+		moved := m.Scale(1 / total).TransformPoint(p)
+		if d := moved.Sub(p).Len(); d > worst {
+			worst = d
+		}
+	}
+	return worst
+}
+
+// isSkinned reports whether dmxVertexData is weighted to the joints of
+// model. Other meshes are rigid and follow their dag.
+func isSkinned(dmxVertexData *dmx.DmeVertexData, model *dmx.DmeModel) bool {
+	return dmxVertexData.JointCount > 0 && len(model.JointTransforms) > 0
+}
+
+// weightedJoints returns the nodes of the joints with weight on some
+// position of dmxVertexData.
+func weightedJoints(dmxVertexData *dmx.DmeVertexData, model *dmx.DmeModel, nodeMap map[string]uint32) map[uint32]bool {
+	result := make(map[uint32]bool)
+	for i, ji := range dmxVertexData.JointIndices {
+		if i >= len(dmxVertexData.JointWeights) || dmxVertexData.JointWeights[i] <= 0 {
+			continue
+		}
+		if ji < 0 || int(ji) >= len(model.JointTransforms) {
+			continue
+		}
+		if nodeID, ok := nodeMap[model.JointTransforms[ji].Name]; ok {
+			result[nodeID] = true
+		}
+	}
+	return result
+}
+
+// coversJoints reports whether joints holds every node of used.
+func coversJoints(joints []uint32, used map[uint32]bool) bool {
+	count := 0
+	for _, nodeID := range joints {
+		if used[nodeID] {
+			count++
+		}
+	}
+	return count == len(used)
+}
+
+// jointInfluence is the weight of one joint on a vertex. The joint is an
+// index into the joints of a skin.
+type jointInfluence struct {
+	joint  uint16
+	weight float32
+}
+
+// skinInfluences returns the joint influences on every position of
+// dmxVertexData, strongest first and normalized to sum to one. jointNodes
+// gives the node each joint is exported as; joints that are not in the skin
+// are dropped, and influences of joints exported as the same node are
+// merged. With -limit-influences only the four strongest influences of a
+// position are kept.
+func skinInfluences(skin *gltf.Skin, dmxVertexData *dmx.DmeVertexData, model *dmx.DmeModel, jointNodes map[string]uint32) ([][]jointInfluence, error) {
+	if len(skin.Joints) > math.MaxUint16+1 {
+		return nil, fmt.Errorf("%d joints do not fit in 16-bit joint indices", len(skin.Joints))
+	}
+	skinJoints := make(map[uint32]uint16, len(skin.Joints))
+	for i, nodeID := range skin.Joints {
+		skinJoints[nodeID] = uint16(i)
+	}
+	jc := int(dmxVertexData.JointCount)
+	if len(dmxVertexData.JointIndices) < len(dmxVertexData.Positions)*jc ||
+		len(dmxVertexData.JointWeights) < len(dmxVertexData.Positions)*jc {
+		return nil, fmt.Errorf("joint data covers fewer than %d positions", len(dmxVertexData.Positions))
+	}
+	result := make([][]jointInfluence, len(dmxVertexData.Positions))
+	for i := range dmxVertexData.Positions {
+		var influences []jointInfluence
+		var total float32
+	next:
+		for j := i * jc; j < (i+1)*jc; j++ {
+			w := dmxVertexData.JointWeights[j]
+			ji := int(dmxVertexData.JointIndices[j])
+			if w <= 0 || ji < 0 || ji >= len(model.JointTransforms) {
+				continue
+			}
+			nodeID, ok := jointNodes[model.JointTransforms[ji].Name]
+			if !ok {
+				continue
+			}
+			joint, ok := skinJoints[nodeID]
+			if !ok {
+				continue
+			}
+			for k := range influences {
+				if influences[k].joint == joint {
+					influences[k].weight += w
+					continue next
+				}
+			}
+			influences = append(influences, jointInfluence{joint, w})
+		}
+		sort.SliceStable(influences, func(a, b int) bool {
+			return influences[a].weight > influences[b].weight
+		})
+		if *argLimitInfluences && len(influences) > 4 {
+			influences = influences[:4]
+		}
+		for _, influence := range influences {
+			total += influence.weight
+		}
+		for j := range influences {
+			influences[j].weight /= total
+		}
+		result[i] = influences
+	}
+	return result, nil
+}
+
+// influenceSets splits influences into sets of four joints and weights per
+// vertex, as many sets as the vertex with the most influences needs.
+func influenceSets(influences [][]jointInfluence) ([][][4]uint16, [][][4]float32) {
+	count := 1
+	for _, vertex := range influences {
+		if n := (len(vertex) + 3) / 4; n > count {
+			count = n
+		}
+	}
+	joints := make([][][4]uint16, count)
+	weights := make([][][4]float32, count)
+	for set := range joints {
+		joints[set] = make([][4]uint16, len(influences))
+		weights[set] = make([][4]float32, len(influences))
+	}
+	for i, vertex := range influences {
+		for j, influence := range vertex {
+			joints[j/4][i][j%4] = influence.joint
+			weights[j/4][i][j%4] = influence.weight
+		}
+	}
+	return joints, weights
+}
+
+// gltfJoints converts joint indices into a skin of jointCount joints to
+// uint8, or keeps them as uint16 when uint8 cannot address every joint.
+func gltfJoints(joints [][4]uint16, jointCount int) any {
+	if jointCount > math.MaxUint8+1 {
+		return joints
+	}
+	result := make([][4]uint8, len(joints))
+	for i, v := range joints {
+		result[i] = [4]uint8{uint8(v[0]), uint8(v[1]), uint8(v[2]), uint8(v[3])}
+	}
+	return result
+}
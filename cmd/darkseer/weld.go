@@ -0,0 +1,77 @@
+package main
+
+import "fmt"
+
+// welder merges face corners into shared vertices. Two corners become the
+// same vertex only if every stream passed to weldStream holds the same value
+// for both of them.
+type welder struct {
+	// vertex holds the vertex each corner was merged into. Vertices are
+	// numbered in the order their first corner appears.
+	vertex []int32
+	count  int
+	// err is the first stream length mismatch.
+	err error
+}
+
+func newWelder(corners int) *welder {
+	w := &welder{vertex: make([]int32, corners)}
+	if corners > 0 {
+		w.count = 1
+	}
+	return w
+}
+
+// weldStream splits the vertices of w so that no vertex holds corners with
+// different values in stream, which has one value per corner. An empty
+// stream is one the mesh does not have and is skipped. A stream of any other
+// length leaves the vertices as they are and is reported by w.err.
+func weldStream[T comparable](w *welder, name string, stream []T) {
+	if len(stream) == 0 || w.err != nil {
+		return
+	}
+	if len(stream) != len(w.vertex) {
+		w.err = fmt.Errorf("%s has %d values for %d face corners", name, len(stream), len(w.vertex))
+		return
+	}
+	type key struct {
+		vertex int32
+		value  T
+	}
+	ids := make(map[key]int32, w.count)
+	for i, v := range w.vertex {
+		k := key{v, stream[i]}
+		id, ok := ids[k]
+		if !ok {
+			id = int32(len(ids))
+			ids[k] = id
+		}
+		w.vertex[i] = id
+	}
+	w.count = len(ids)
+}
+
+// corners returns the first corner of each vertex.
+func (w *welder) corners() []int32 {
+	result := make([]int32, w.count)
+	seen := make([]bool, w.count)
+	for i, v := range w.vertex {
+		if !seen[v] {
+			seen[v] = true
+			result[v] = int32(i)
+		}
+	}
+	return result
+}
+
+// remap converts indices into face corners to indices into vertices.
+func (w *welder) remap(indices []int32) ([]int32, error) {
+	result := make([]int32, len(indices))
+	for i, c := range indices {
+		if c < 0 || int(c) >= len(w.vertex) {
+			return nil, fmt.Errorf("index %d out of range of %d vertices", c, len(w.vertex))
+		}
+		result[i] = w.vertex[c]
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package dmx
+
+import "strings"
+
+// RawValues converts a value of the control to the values of its raw
+// controls. A control with two raw controls is ranged: values below 0.5
+// drive the first raw control and values above 0.5 drive the second.
+func (c *DmeCombinationInputControl) RawValues(value float32) []float32 {
+	switch len(c.RawControlNames) {
+	case 0:
+		return nil
+	case 2:
+		return []float32{clamp01(1 - 2*value), clamp01(2*value - 1)}
+	}
+	result := make([]float32, len(c.RawControlNames))
+	for i := range result {
+		result[i] = value
+	}
+	return result
+}
+
+// DeltaWeights returns the weight of each delta state named in deltaNames
+// for the given control values, keyed by control name. Controls without a
+// value keep the value the operator stores for them.
+//
+// A delta state named after several raw controls joined by underscores is
+// a corrective: its weight is the product of their values. A domination
+// rule scales a delta state that combines all of its suppressed controls
+// down as its dominators come on.
+func (o *DmeCombinationOperator) DeltaWeights(values map[string]float32, deltaNames []string) []float32 {
+	raw := make(map[string]float32)
+	for i, control := range o.Controls {
+		if control == nil {
+			continue
+		}
+		value, ok := values[control.Name]
+		if !ok && i < len(o.ControlValues) {
+			value = o.ControlValues[i][0]
+		}
+		for j, v := range control.RawValues(value) {
+			raw[control.RawControlNames[j]] = v
+		}
+	}
+	result := make([]float32, len(deltaNames))
+	for i, name := range deltaNames {
+		parts := strings.Split(name, "_")
+		weight := product(raw, parts)
+		for _, rule := range o.Dominators {
+			if rule != nil && containsAll(parts, rule.Suppressed) {
+				weight *= 1 - product(raw, rule.Dominators)
+			}
+		}
+		result[i] = weight
+	}
+	return result
+}
+
+func product(values map[string]float32, names []string) float32 {
+	result := float32(1)
+	for _, name := range names {
+		result *= values[name]
+	}
+	return result
+}
+
+func containsAll(set, names []string) bool {
+	if len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		found := false
+		for _, s := range set {
+			if s == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,208 @@
+package dmx
+
+import (
+	"github.com/aoisensi/darkseer/dmx/dmxmath"
+	"github.com/aoisensi/darkseer/dmx/internal"
+)
+
+// Interpolation is the interpolation type of one side of a log key. The
+// values are those of Source's interpolatortypes.h.
+type Interpolation int32
+
+const (
+	InterpolateDefault Interpolation = iota
+	InterpolateCatmullRomNormalizeX
+	InterpolateEaseIn
+	InterpolateEaseOut
+	InterpolateEaseInOut
+	InterpolateBSpline
+	InterpolateLinear
+	InterpolateKochanekBartels
+	InterpolateKochanekBartelsEarly
+	InterpolateKochanekBartelsLate
+	InterpolateSimpleCubic
+	InterpolateCatmullRom
+	InterpolateCatmullRomNormalize
+	InterpolateCatmullRomTangent
+	InterpolateExponentialDecay
+	InterpolateHold
+)
+
+// CurveType packs the interpolation on both sides of a log key: the incoming
+// side in bits 8-15 and the outgoing side in bits 0-7.
+type CurveType int32
+
+func MakeCurveType(in, out Interpolation) CurveType {
+	return CurveType((in&0xff)<<8 | out&0xff)
+}
+
+// In returns the interpolation used towards the key.
+func (c CurveType) In() Interpolation {
+	return Interpolation(c>>8) & 0xff
+}
+
+// Out returns the interpolation used away from the key.
+func (c CurveType) Out() Interpolation {
+	return Interpolation(c) & 0xff
+}
+
+type DmeCurveInfo struct {
+	Name             string
+	DefaultCurveType CurveType
+	MinValue         float32
+	MaxValue         float32
+}
+
+func parseCurveInfo(e *internal.Element) *DmeCurveInfo {
+	if e == nil {
+		return nil
+	}
+	curveInfo := &DmeCurveInfo{
+		Name:     e.Name,
+		MaxValue: 1,
+	}
+	if defaultCurveType, ok := e.Attributes["defaultCurveType"].(int32); ok {
+		curveInfo.DefaultCurveType = CurveType(defaultCurveType)
+	}
+	if minValue, ok := e.Attributes["minValue"].(float32); ok {
+		curveInfo.MinValue = minValue
+	}
+	if maxValue, ok := e.Attributes["maxValue"].(float32); ok {
+		curveInfo.MaxValue = maxValue
+	}
+	return curveInfo
+}
+
+// curveKind is how a segment between two keys is evaluated.
+type curveKind int
+
+const (
+	curveLinear curveKind = iota
+	curveStep
+	curveEaseInOut
+	curveEaseIn
+	curveEaseOut
+	curveSpline
+)
+
+func (i Interpolation) kind() curveKind {
+	switch i {
+	case InterpolateHold:
+		return curveStep
+	case InterpolateEaseInOut:
+		return curveEaseInOut
+	case InterpolateEaseIn:
+		return curveEaseIn
+	case InterpolateEaseOut:
+		return curveEaseOut
+	case InterpolateCatmullRomNormalizeX, InterpolateBSpline, InterpolateKochanekBartels,
+		InterpolateKochanekBartelsEarly, InterpolateKochanekBartelsLate, InterpolateSimpleCubic,
+		InterpolateCatmullRom, InterpolateCatmullRomNormalize, InterpolateCatmullRomTangent:
+		return curveSpline
+	default:
+		return curveLinear
+	}
+}
+
+// ease remaps the segment parameter s for the ease curve kinds.
+func (k curveKind) ease(s float32) float32 {
+	switch k {
+	case curveEaseInOut:
+		return s * s * (3 - 2*s)
+	case curveEaseIn:
+		return s * s
+	case curveEaseOut:
+		return 1 - (1-s)*(1-s)
+	default:
+		return s
+	}
+}
+
+// splineValue evaluates a Catmull-Rom spline on the segment between keys
+// i-1 and i at parameter s. The tangent of each key is the slope between
+// its neighbours, so uneven key spacing is honoured. It reports false for
+// value types that have no spline form.
+func splineValue[T LogType](l *DmeLogLayer[T], i int, s float32) (T, bool) {
+	var zero T
+	quat := false
+	switch any(zero).(type) {
+	case float32, [2]float32, [3]float32:
+		quat = l.Type == "DmeQAngleLogLayer"
+	case [4]float32:
+		quat = l.Type != "DmeVector4LogLayer"
+	default:
+		return zero, false
+	}
+	n := len(l.Values)
+	ref := toSplinePoint(l.Values[i-1], l.Type)
+	point := func(k int) dmxmath.Vec4 {
+		p := toSplinePoint(l.Values[k], l.Type)
+		if quat {
+			p = dmxmath.Vec4(dmxmath.Quat(p).Align(dmxmath.Quat(ref)))
+		}
+		return p
+	}
+	tangent := func(k int) dmxmath.Vec4 {
+		a, b := k-1, k+1
+		if a < 0 {
+			a = k
+		}
+		if b >= n {
+			b = k
+		}
+		dt := float32(l.Times[b] - l.Times[a])
+		if dt == 0 {
+			return dmxmath.Vec4{}
+		}
+		return point(b).Sub(point(a)).Scale(1 / dt)
+	}
+	p0, p1 := point(i-1), point(i)
+	dt := float32(l.Times[i] - l.Times[i-1])
+	m0, m1 := tangent(i-1).Scale(dt), tangent(i).Scale(dt)
+	s2, s3 := s*s, s*s*s
+	p := p0.Scale(2*s3 - 3*s2 + 1).
+		Add(m0.Scale(s3 - 2*s2 + s)).
+		Add(p1.Scale(-2*s3 + 3*s2)).
+		Add(m1.Scale(s3 - s2))
+	if quat {
+		p = dmxmath.Vec4(dmxmath.Quat(p).Normalize())
+	}
+	return fromSplinePoint[T](p, l.Type), true
+}
+
+func toSplinePoint[T LogType](v T, layerType string) dmxmath.Vec4 {
+	switch v := any(v).(type) {
+	case float32:
+		return dmxmath.Vec4{v}
+	case [2]float32:
+		return dmxmath.Vec4{v[0], v[1]}
+	case [3]float32:
+		if layerType == "DmeQAngleLogLayer" {
+			return dmxmath.Vec4(dmxmath.QuatFromQAngle(v))
+		}
+		return dmxmath.Vec4{v[0], v[1], v[2]}
+	case [4]float32:
+		return v
+	}
+	panic("unreachable")
+}
+
+func fromSplinePoint[T LogType](p dmxmath.Vec4, layerType string) T {
+	var result any
+	var zero T
+	switch any(zero).(type) {
+	case float32:
+		result = p[0]
+	case [2]float32:
+		result = [2]float32{p[0], p[1]}
+	case [3]float32:
+		if layerType == "DmeQAngleLogLayer" {
+			result = [3]float32(dmxmath.Quat(p).QAngle())
+		} else {
+			result = [3]float32{p[0], p[1], p[2]}
+		}
+	case [4]float32:
+		result = [4]float32(p)
+	}
+	return result.(T)
+}
@@ -0,0 +1,36 @@
+package dmx
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLogLayerClampCurveTypes(t *testing.T) {
+	// Curve types are the raw values of Source's interpolatortypes.h, as
+	// they are stored in SFM logs.
+	tests := []struct {
+		name      string
+		curveType int32
+		at        Time
+		want      float32
+	}{
+		{name: "hold", curveType: 15, at: 2500, want: 0},
+		{name: "linear", curveType: 6, at: 2500, want: 2.5},
+		{name: "exponential decay", curveType: 14, at: 2500, want: 2.5},
+		{name: "ease in", curveType: 2, at: 2500, want: 0.625},
+		{name: "ease out", curveType: 3, at: 2500, want: 4.375},
+		{name: "ease in and out", curveType: 4, at: 2500, want: 1.5625},
+		{name: "catmull-rom", curveType: 11, at: 5000, want: 4.375},
+	}
+	for _, tt := range tests {
+		curveType := CurveType(tt.curveType<<8 | tt.curveType)
+		layer := &DmeLogLayer[float32]{
+			Times:      []Time{0, 10000, 20000},
+			Values:     []float32{0, 10, 30},
+			CurveTypes: []CurveType{curveType, curveType, curveType},
+		}
+		if got := layer.Clamp(tt.at); math.Abs(float64(got-tt.want)) > 1e-5 {
+			t.Errorf("%s: Clamp(%d) = %g, want %g", tt.name, tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package dmxmath
+
+// Mat4 is a 4x4 matrix stored column-major, the layout glTF uses. It
+// transforms column vectors, so a.Mul(b) applies b first.
+type Mat4 [16]float32
+
+func Mat4Identity() Mat4 {
+	return Mat4{
+		1, 0, 0, 0,
+		0, 1, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// Mat4FromVMatrix converts a DMX matrix attribute, which is stored row by
+// row with the translation in the last column, to a Mat4.
+func Mat4FromVMatrix(v [4][4]float32) Mat4 {
+	var m Mat4
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			m[c*4+r] = v[r][c]
+		}
+	}
+	return m
+}
+
+// VMatrix converts m back to the row by row layout of DMX matrix attributes.
+func (m Mat4) VMatrix() [4][4]float32 {
+	var v [4][4]float32
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			v[r][c] = m[c*4+r]
+		}
+	}
+	return v
+}
+
+// Compose returns the matrix translation * rotation * scale.
+func Compose(t Vec3, r Quat, s Vec3) Mat4 {
+	x, y, z, w := r[0], r[1], r[2], r[3]
+	return Mat4{
+		(1 - 2*y*y - 2*z*z) * s[0], (2*x*y + 2*z*w) * s[0], (2*x*z - 2*y*w) * s[0], 0,
+		(2*x*y - 2*z*w) * s[1], (1 - 2*x*x - 2*z*z) * s[1], (2*y*z + 2*x*w) * s[1], 0,
+		(2*x*z + 2*y*w) * s[2], (2*y*z - 2*x*w) * s[2], (1 - 2*x*x - 2*y*y) * s[2], 0,
+		t[0], t[1], t[2], 1,
+	}
+}
+
+// Decompose splits an affine matrix without shear into translation, rotation
+// and scale. A mirroring matrix gets a negative x scale.
+func (m Mat4) Decompose() (t Vec3, r Quat, s Vec3) {
+	t = m.Translation()
+	cols := [3]Vec3{{m[0], m[1], m[2]}, {m[4], m[5], m[6]}, {m[8], m[9], m[10]}}
+	s = Vec3{cols[0].Len(), cols[1].Len(), cols[2].Len()}
+	if m.Det() < 0 {
+		s[0] = -s[0]
+	}
+	rm := Mat4Identity()
+	for c := 0; c < 3; c++ {
+		if s[c] == 0 {
+			continue
+		}
+		col := cols[c].Scale(1 / s[c])
+		rm[c*4+0], rm[c*4+1], rm[c*4+2] = col[0], col[1], col[2]
+	}
+	return t, QuatFromMat4(rm), s
+}
+
+// Mul returns m * n.
+func (m Mat4) Mul(n Mat4) Mat4 {
+	var result Mat4
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			var v float32
+			for k := 0; k < 4; k++ {
+				v += m[k*4+r] * n[c*4+k]
+			}
+			result[c*4+r] = v
+		}
+	}
+	return result
+}
+
+func (m Mat4) Transpose() Mat4 {
+	var result Mat4
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			result[r*4+c] = m[c*4+r]
+		}
+	}
+	return result
+}
+
+func (m Mat4) Det() float32 {
+	a := m.cofactors()
+	return m[0]*a[0] + m[1]*a[4] + m[2]*a[8] + m[3]*a[12]
+}
+
+// Inverse returns the inverse of m, or the zero matrix if m is singular.
+func (m Mat4) Inverse() Mat4 {
+	a := m.cofactors()
+	det := m[0]*a[0] + m[1]*a[4] + m[2]*a[8] + m[3]*a[12]
+	if det == 0 {
+		return Mat4{}
+	}
+	for i := range a {
+		a[i] /= det
+	}
+	return a
+}
+
+// cofactors returns the adjugate of m.
+func (m Mat4) cofactors() Mat4 {
+	var inv Mat4
+	inv[0] = m[5]*m[10]*m[15] - m[5]*m[11]*m[14] - m[9]*m[6]*m[15] + m[9]*m[7]*m[14] + m[13]*m[6]*m[11] - m[13]*m[7]*m[10]
+	inv[4] = -m[4]*m[10]*m[15] + m[4]*m[11]*m[14] + m[8]*m[6]*m[15] - m[8]*m[7]*m[14] - m[12]*m[6]*m[11] + m[12]*m[7]*m[10]
+	inv[8] = m[4]*m[9]*m[15] - m[4]*m[11]*m[13] - m[8]*m[5]*m[15] + m[8]*m[7]*m[13] + m[12]*m[5]*m[11] - m[12]*m[7]*m[9]
+	inv[12] = -m[4]*m[9]*m[14] + m[4]*m[10]*m[13] + m[8]*m[5]*m[14] - m[8]*m[6]*m[13] - m[12]*m[5]*m[10] + m[12]*m[6]*m[9]
+	inv[1] = -m[1]*m[10]*m[15] + m[1]*m[11]*m[14] + m[9]*m[2]*m[15] - m[9]*m[3]*m[14] - m[13]*m[2]*m[11] + m[13]*m[3]*m[10]
+	inv[5] = m[0]*m[10]*m[15] - m[0]*m[11]*m[14] - m[8]*m[2]*m[15] + m[8]*m[3]*m[14] + m[12]*m[2]*m[11] - m[12]*m[3]*m[10]
+	inv[9] = -m[0]*m[9]*m[15] + m[0]*m[11]*m[13] + m[8]*m[1]*m[15] - m[8]*m[3]*m[13] - m[12]*m[1]*m[11] + m[12]*m[3]*m[9]
+	inv[13] = m[0]*m[9]*m[14] - m[0]*m[10]*m[13] - m[8]*m[1]*m[14] + m[8]*m[2]*m[13] + m[12]*m[1]*m[10] - m[12]*m[2]*m[9]
+	inv[2] = m[1]*m[6]*m[15] - m[1]*m[7]*m[14] - m[5]*m[2]*m[15] + m[5]*m[3]*m[14] + m[13]*m[2]*m[7] - m[13]*m[3]*m[6]
+	inv[6] = -m[0]*m[6]*m[15] + m[0]*m[7]*m[14] + m[4]*m[2]*m[15] - m[4]*m[3]*m[14] - m[12]*m[2]*m[7] + m[12]*m[3]*m[6]
+	inv[10] = m[0]*m[5]*m[15] - m[0]*m[7]*m[13] - m[4]*m[1]*m[15] + m[4]*m[3]*m[13] + m[12]*m[1]*m[7] - m[12]*m[3]*m[5]
+	inv[14] = -m[0]*m[5]*m[14] + m[0]*m[6]*m[13] + m[4]*m[1]*m[14] - m[4]*m[2]*m[13] - m[12]*m[1]*m[6] + m[12]*m[2]*m[5]
+	inv[3] = -m[1]*m[6]*m[11] + m[1]*m[7]*m[10] + m[5]*m[2]*m[11] - m[5]*m[3]*m[10] - m[9]*m[2]*m[7] + m[9]*m[3]*m[6]
+	inv[7] = m[0]*m[6]*m[11] - m[0]*m[7]*m[10] - m[4]*m[2]*m[11] + m[4]*m[3]*m[10] + m[8]*m[2]*m[7] - m[8]*m[3]*m[6]
+	inv[11] = -m[0]*m[5]*m[11] + m[0]*m[7]*m[9] + m[4]*m[1]*m[11] - m[4]*m[3]*m[9] - m[8]*m[1]*m[7] + m[8]*m[3]*m[5]
+	inv[15] = m[0]*m[5]*m[10] - m[0]*m[6]*m[9] - m[4]*m[1]*m[10] + m[4]*m[2]*m[9] + m[8]*m[1]*m[6] - m[8]*m[2]*m[5]
+	return inv
+}
+
+func (m Mat4) Translation() Vec3 {
+	return Vec3{m[12], m[13], m[14]}
+}
+
+// TransformPoint returns m applied to the point p.
+func (m Mat4) TransformPoint(p Vec3) Vec3 {
+	return Vec3{
+		m[0]*p[0] + m[4]*p[1] + m[8]*p[2] + m[12],
+		m[1]*p[0] + m[5]*p[1] + m[9]*p[2] + m[13],
+		m[2]*p[0] + m[6]*p[1] + m[10]*p[2] + m[14],
+	}
+}
+
+// TransformVector returns m applied to the direction v, ignoring translation.
+func (m Mat4) TransformVector(v Vec3) Vec3 {
+	return Vec3{
+		m[0]*v[0] + m[4]*v[1] + m[8]*v[2],
+		m[1]*v[0] + m[5]*v[1] + m[9]*v[2],
+		m[2]*v[0] + m[6]*v[1] + m[10]*v[2],
+	}
+}
+
+// Add returns the component-wise sum of m and n.
+func (m Mat4) Add(n Mat4) Mat4 {
+	for i := range m {
+		m[i] += n[i]
+	}
+	return m
+}
+
+// Scale returns every component of m multiplied by s.
+func (m Mat4) Scale(s float32) Mat4 {
+	for i := range m {
+		m[i] *= s
+	}
+	return m
+}
@@ -0,0 +1,189 @@
+package dmxmath
+
+import "math"
+
+// Quat is a rotation quaternion stored as x, y, z, w.
+type Quat [4]float32
+
+// QAngle is a Source engine Euler angle: pitch, yaw and roll in degrees.
+type QAngle [3]float32
+
+func QuatIdentity() Quat {
+	return Quat{0, 0, 0, 1}
+}
+
+// QuatFromAxisAngle returns the rotation of angle radians around axis.
+func QuatFromAxisAngle(axis Vec3, angle float32) Quat {
+	axis = axis.Normalize()
+	s, c := math.Sincos(float64(angle) / 2)
+	return Quat{axis[0] * float32(s), axis[1] * float32(s), axis[2] * float32(s), float32(c)}
+}
+
+// QuatFromQAngle converts a QAngle the same way the engine's
+// AngleQuaternion does.
+func QuatFromQAngle(a QAngle) Quat {
+	sy, cy := math.Sincos(float64(a[1]) * math.Pi / 360)
+	sp, cp := math.Sincos(float64(a[0]) * math.Pi / 360)
+	sr, cr := math.Sincos(float64(a[2]) * math.Pi / 360)
+	srXcp, crXsp := sr*cp, cr*sp
+	crXcp, srXsp := cr*cp, sr*sp
+	return Quat{
+		float32(srXcp*cy - crXsp*sy),
+		float32(crXsp*cy + srXcp*sy),
+		float32(crXcp*sy - srXsp*cy),
+		float32(crXcp*cy + srXsp*sy),
+	}
+}
+
+// QuatFromMat4 returns the rotation of the upper 3x3 part of m, which must be
+// orthonormal.
+func QuatFromMat4(m Mat4) Quat {
+	m00, m11, m22 := float64(m[0]), float64(m[5]), float64(m[10])
+	trace := m00 + m11 + m22
+	var q [4]float64
+	switch {
+	case trace > 0:
+		s := math.Sqrt(trace+1) * 2
+		q = [4]float64{
+			float64(m[6]-m[9]) / s,
+			float64(m[8]-m[2]) / s,
+			float64(m[1]-m[4]) / s,
+			s / 4,
+		}
+	case m00 > m11 && m00 > m22:
+		s := math.Sqrt(1+m00-m11-m22) * 2
+		q = [4]float64{
+			s / 4,
+			float64(m[4]+m[1]) / s,
+			float64(m[8]+m[2]) / s,
+			float64(m[6]-m[9]) / s,
+		}
+	case m11 > m22:
+		s := math.Sqrt(1+m11-m00-m22) * 2
+		q = [4]float64{
+			float64(m[4]+m[1]) / s,
+			s / 4,
+			float64(m[9]+m[6]) / s,
+			float64(m[8]-m[2]) / s,
+		}
+	default:
+		s := math.Sqrt(1+m22-m00-m11) * 2
+		q = [4]float64{
+			float64(m[8]+m[2]) / s,
+			float64(m[9]+m[6]) / s,
+			s / 4,
+			float64(m[1]-m[4]) / s,
+		}
+	}
+	return Quat{float32(q[0]), float32(q[1]), float32(q[2]), float32(q[3])}.Normalize()
+}
+
+// QAngle converts q to Euler angles the same way the engine's
+// QuaternionAngles does.
+func (q Quat) QAngle() QAngle {
+	m := q.Mat4()
+	forward := Vec3{m[0], m[1], m[2]}
+	left := Vec3{m[4], m[5], m[6]}
+	up := m[10]
+	xyDist := math.Sqrt(float64(forward[0]*forward[0] + forward[1]*forward[1]))
+	const toDeg = 180 / math.Pi
+	if xyDist > 0.001 {
+		return QAngle{
+			float32(math.Atan2(float64(-forward[2]), xyDist) * toDeg),
+			float32(math.Atan2(float64(forward[1]), float64(forward[0])) * toDeg),
+			float32(math.Atan2(float64(left[2]), float64(up)) * toDeg),
+		}
+	}
+	return QAngle{
+		float32(math.Atan2(float64(-forward[2]), xyDist) * toDeg),
+		float32(math.Atan2(float64(-left[0]), float64(left[1])) * toDeg),
+		0,
+	}
+}
+
+// Mul returns the rotation q * r, which applies r first.
+func (q Quat) Mul(r Quat) Quat {
+	return Quat{
+		q[3]*r[0] + q[0]*r[3] + q[1]*r[2] - q[2]*r[1],
+		q[3]*r[1] - q[0]*r[2] + q[1]*r[3] + q[2]*r[0],
+		q[3]*r[2] + q[0]*r[1] - q[1]*r[0] + q[2]*r[3],
+		q[3]*r[3] - q[0]*r[0] - q[1]*r[1] - q[2]*r[2],
+	}
+}
+
+func (q Quat) Conjugate() Quat {
+	return Quat{-q[0], -q[1], -q[2], q[3]}
+}
+
+func (q Quat) Inverse() Quat {
+	d := q.Dot(q)
+	if d == 0 {
+		return q
+	}
+	c := q.Conjugate()
+	return Quat{c[0] / d, c[1] / d, c[2] / d, c[3] / d}
+}
+
+func (q Quat) Neg() Quat {
+	return Quat{-q[0], -q[1], -q[2], -q[3]}
+}
+
+func (q Quat) Dot(r Quat) float32 {
+	return q[0]*r[0] + q[1]*r[1] + q[2]*r[2] + q[3]*r[3]
+}
+
+func (q Quat) Len() float32 {
+	return float32(math.Sqrt(float64(q.Dot(q))))
+}
+
+// Normalize returns q scaled to unit length, or the identity if q has zero
+// length.
+func (q Quat) Normalize() Quat {
+	l := q.Len()
+	if l == 0 {
+		return QuatIdentity()
+	}
+	return Quat{q[0] / l, q[1] / l, q[2] / l, q[3] / l}
+}
+
+// Align returns q or -q, whichever is in the same hemisphere as ref. Both
+// represent the same rotation.
+func (q Quat) Align(ref Quat) Quat {
+	if q.Dot(ref) < 0 {
+		return q.Neg()
+	}
+	return q
+}
+
+// Slerp spherically interpolates from q to r along the shortest arc.
+func (q Quat) Slerp(r Quat, t float32) Quat {
+	r = r.Align(q)
+	cos := float64(q.Dot(r))
+	if cos > 0.9995 {
+		// Nearly parallel; fall back to normalized lerp.
+		return Quat(Vec4(q).Lerp(Vec4(r), t)).Normalize()
+	}
+	theta := math.Acos(math.Min(cos, 1))
+	sin := math.Sin(theta)
+	a := float32(math.Sin((1-float64(t))*theta) / sin)
+	b := float32(math.Sin(float64(t)*theta) / sin)
+	return Quat(Vec4(q).Scale(a).Add(Vec4(r).Scale(b)))
+}
+
+// Rotate returns v rotated by q.
+func (q Quat) Rotate(v Vec3) Vec3 {
+	u := Vec3{q[0], q[1], q[2]}
+	t := u.Cross(v).Scale(2)
+	return v.Add(t.Scale(q[3])).Add(u.Cross(t))
+}
+
+// Angle returns the rotation angle of q in radians, in [0, pi].
+func (q Quat) Angle() float32 {
+	w := math.Abs(float64(q.Normalize()[3]))
+	return float32(2 * math.Acos(math.Min(w, 1)))
+}
+
+// Mat4 returns the rotation matrix of q.
+func (q Quat) Mat4() Mat4 {
+	return Compose(Vec3{}, q, Vec3{1, 1, 1})
+}
@@ -0,0 +1,98 @@
+// Package dmxmath provides vector, quaternion and matrix operations on the
+// array types DMX attributes decode to.
+package dmxmath
+
+import "math"
+
+type Vec2 [2]float32
+
+func (v Vec2) Add(w Vec2) Vec2 {
+	return Vec2{v[0] + w[0], v[1] + w[1]}
+}
+
+func (v Vec2) Sub(w Vec2) Vec2 {
+	return Vec2{v[0] - w[0], v[1] - w[1]}
+}
+
+func (v Vec2) Scale(s float32) Vec2 {
+	return Vec2{v[0] * s, v[1] * s}
+}
+
+func (v Vec2) Lerp(w Vec2, t float32) Vec2 {
+	return v.Add(w.Sub(v).Scale(t))
+}
+
+type Vec3 [3]float32
+
+func (v Vec3) Add(w Vec3) Vec3 {
+	return Vec3{v[0] + w[0], v[1] + w[1], v[2] + w[2]}
+}
+
+func (v Vec3) Sub(w Vec3) Vec3 {
+	return Vec3{v[0] - w[0], v[1] - w[1], v[2] - w[2]}
+}
+
+func (v Vec3) Scale(s float32) Vec3 {
+	return Vec3{v[0] * s, v[1] * s, v[2] * s}
+}
+
+// Mul multiplies v and w component-wise.
+func (v Vec3) Mul(w Vec3) Vec3 {
+	return Vec3{v[0] * w[0], v[1] * w[1], v[2] * w[2]}
+}
+
+func (v Vec3) Neg() Vec3 {
+	return Vec3{-v[0], -v[1], -v[2]}
+}
+
+func (v Vec3) Dot(w Vec3) float32 {
+	return v[0]*w[0] + v[1]*w[1] + v[2]*w[2]
+}
+
+func (v Vec3) Cross(w Vec3) Vec3 {
+	return Vec3{
+		v[1]*w[2] - v[2]*w[1],
+		v[2]*w[0] - v[0]*w[2],
+		v[0]*w[1] - v[1]*w[0],
+	}
+}
+
+func (v Vec3) Len() float32 {
+	return float32(math.Sqrt(float64(v.Dot(v))))
+}
+
+// Normalize returns v scaled to unit length, or v itself if it has zero
+// length.
+func (v Vec3) Normalize() Vec3 {
+	l := v.Len()
+	if l == 0 {
+		return v
+	}
+	return v.Scale(1 / l)
+}
+
+func (v Vec3) Lerp(w Vec3, t float32) Vec3 {
+	return v.Add(w.Sub(v).Scale(t))
+}
+
+type Vec4 [4]float32
+
+func (v Vec4) Add(w Vec4) Vec4 {
+	return Vec4{v[0] + w[0], v[1] + w[1], v[2] + w[2], v[3] + w[3]}
+}
+
+func (v Vec4) Sub(w Vec4) Vec4 {
+	return Vec4{v[0] - w[0], v[1] - w[1], v[2] - w[2], v[3] - w[3]}
+}
+
+func (v Vec4) Scale(s float32) Vec4 {
+	return Vec4{v[0] * s, v[1] * s, v[2] * s, v[3] * s}
+}
+
+func (v Vec4) Dot(w Vec4) float32 {
+	return v[0]*w[0] + v[1]*w[1] + v[2]*w[2] + v[3]*w[3]
+}
+
+func (v Vec4) Lerp(w Vec4, t float32) Vec4 {
+	return v.Add(w.Sub(v).Scale(t))
+}
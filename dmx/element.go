@@ -1,6 +1,8 @@
 package dmx
 
 import (
+	"sort"
+
 	"github.com/aoisensi/darkseer/dmx/internal"
 	"github.com/google/uuid"
 )
@@ -12,26 +14,92 @@ type DmElement struct {
 	Model         *DmeModel
 	Skeleton      *DmeModel
 	AnimationList *DmeAnimationList
+	ModelRoot     *DmeModelRoot
 }
 
 func parseElement(e *internal.Element) *DmElement {
 	if e == nil {
 		return nil
 	}
-	if e.Type != "DmElement" {
-		panic("dmx: invalid element type")
-	}
 	element := &DmElement{Name: e.Name}
-	if model, ok := e.Attributes["model"]; ok && model != nil {
-		element.Model = parseModel(model.(*internal.Element))
-	}
-	if skeleton, ok := e.Attributes["skeleton"]; ok && skeleton != nil {
-		element.Skeleton = parseModel(skeleton.(*internal.Element))
-	}
-	if animationList, ok := e.Attributes["animationList"]; ok && animationList != nil {
-		element.AnimationList = parseAnimationList(animationList.(*internal.Element))
+	switch e.Type {
+	default:
+		panic("dmx: invalid element type")
+	case "DmElement":
+		if root := findElementAttribute(e, "DmeModelRoot", "modelRoot", "root"); root != nil {
+			element.ModelRoot = parseModelRoot(root)
+		} else {
+			element.ModelRoot = parseModelRoot(e)
+		}
+		if animationList := findElementAttribute(e, "DmeAnimationList", "animationList"); animationList != nil {
+			element.AnimationList = parseAnimationList(animationList)
+		}
+	case "DmeModelRoot":
+		element.ModelRoot = parseModelRoot(e)
 	}
+	element.Model = element.ModelRoot.Model
+	element.Skeleton = element.ModelRoot.Skeleton
 	return element
 	// elementMap[e.ID] = result
 	// return result
 }
+
+// DmeElementRef is an element of any type, kept undecoded. It is used where
+// an attribute may refer to elements this package does not model.
+type DmeElementRef struct {
+	ID   uuid.UUID
+	Type string
+	Name string
+	e    *internal.Element
+}
+
+func parseElementRef(e *internal.Element) *DmeElementRef {
+	if e == nil {
+		return nil
+	}
+	return &DmeElementRef{
+		ID:   e.ID,
+		Type: e.Type,
+		Name: e.Name,
+		e:    e,
+	}
+}
+
+// Attribute returns the raw value of the named attribute. Element
+// attributes are returned as *DmeElementRef and []*DmeElementRef.
+func (r *DmeElementRef) Attribute(name string) any {
+	switch v := r.e.Attributes[name].(type) {
+	case *internal.Element:
+		return parseElementRef(v)
+	case []*internal.Element:
+		list := make([]*DmeElementRef, len(v))
+		for i, e := range v {
+			list[i] = parseElementRef(e)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+// findElementAttribute returns the first of the named attributes that holds
+// an element of type typ. If none of them does, it falls back to the first
+// attribute, in name order, holding an element of type typ.
+func findElementAttribute(e *internal.Element, typ string, names ...string) *internal.Element {
+	for _, name := range names {
+		if v, ok := e.Attributes[name].(*internal.Element); ok && v != nil && v.Type == typ {
+			return v
+		}
+	}
+	keys := make([]string, 0, len(e.Attributes))
+	for key := range e.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if v, ok := e.Attributes[key].(*internal.Element); ok && v != nil && v.Type == typ {
+			return v
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package dmx
 
 import (
 	"image/color"
+	"math"
 
 	"github.com/aoisensi/darkseer/dmx/internal"
 )
@@ -46,6 +47,30 @@ func parseChannelsClip(e *internal.Element) *DmeChannelsClip {
 	}
 }
 
+// LocalTime converts a log time to the time since the start of the clip.
+func (c *DmeChannelsClip) LocalTime(t Time) Time {
+	if c.TimeFrame == nil {
+		return t
+	}
+	return c.TimeFrame.ToParent(t) - c.TimeFrame.StartTime
+}
+
+// LogTime converts a time since the start of the clip to a log time.
+func (c *DmeChannelsClip) LogTime(local Time) Time {
+	if c.TimeFrame == nil {
+		return local
+	}
+	return c.TimeFrame.ToChild(local + c.TimeFrame.StartTime)
+}
+
+// Duration returns the length of the clip.
+func (c *DmeChannelsClip) Duration() Time {
+	if c.TimeFrame == nil {
+		return 0
+	}
+	return c.TimeFrame.DurationTime
+}
+
 func parseChannelsClipList(e []*internal.Element) []*DmeChannelsClip {
 	if e == nil {
 		return nil
@@ -59,9 +84,9 @@ func parseChannelsClipList(e []*internal.Element) []*DmeChannelsClip {
 
 type DmeTimeFrame struct {
 	Name         string
-	StartTime    int32
-	DurationTime int32
-	OffsetTime   int32
+	StartTime    Time
+	DurationTime Time
+	OffsetTime   Time
 	Scale        float32
 }
 
@@ -69,24 +94,69 @@ func parseTimeFrame(e *internal.Element) *DmeTimeFrame {
 	if e == nil {
 		return nil
 	}
-	return &DmeTimeFrame{
-		Name:         e.Name,
-		StartTime:    e.Attributes["startTime"].(int32),
-		DurationTime: e.Attributes["durationTime"].(int32),
-		OffsetTime:   e.Attributes["offsetTime"].(int32),
-		Scale:        e.Attributes["scale"].(float32),
+	timeFrame := &DmeTimeFrame{
+		Name:  e.Name,
+		Scale: 1,
+	}
+	// Older files store int32 startTime, durationTime and offsetTime;
+	// newer ones store time attributes named start, duration and offset.
+	for _, name := range []string{"start", "startTime"} {
+		if v, ok := e.Attributes[name]; ok {
+			timeFrame.StartTime = parseTime(v)
+		}
+	}
+	for _, name := range []string{"duration", "durationTime"} {
+		if v, ok := e.Attributes[name]; ok {
+			timeFrame.DurationTime = parseTime(v)
+		}
+	}
+	for _, name := range []string{"offset", "offsetTime"} {
+		if v, ok := e.Attributes[name]; ok {
+			timeFrame.OffsetTime = parseTime(v)
+		}
+	}
+	if scale, ok := e.Attributes["scale"].(float32); ok && scale != 0 {
+		timeFrame.Scale = scale
+	}
+	return timeFrame
+}
+
+// ToParent converts a time inside the clip, the time its logs use, to the
+// time of the clip's parent.
+func (f *DmeTimeFrame) ToParent(t Time) Time {
+	if f == nil {
+		return t
+	}
+	return Time(math.Round(float64(t-f.OffsetTime)/float64(f.Scale))) + f.StartTime
+}
+
+// ToChild converts a time of the clip's parent to the time inside the clip.
+func (f *DmeTimeFrame) ToChild(t Time) Time {
+	if f == nil {
+		return t
 	}
+	return Time(math.Round(float64(t-f.StartTime)*float64(f.Scale))) + f.OffsetTime
 }
 
 type DmeChannel struct {
 	Name          string
 	FromAttribute string
 	FromIndex     int32
-	ToElement     *DmeTransform
+	ToElement     *DmeElementRef
 	ToAttribute   string
 	ToIndex       int32
-	LogQuaternion *DmeLog[[4]float32]
+	LogInt        *DmeLog[int32]
+	LogFloat      *DmeLog[float32]
+	LogBool       *DmeLog[bool]
+	LogColor      *DmeLog[color.RGBA]
+	LogVector2    *DmeLog[[2]float32]
 	LogVector3    *DmeLog[[3]float32]
+	LogVector4    *DmeLog[[4]float32]
+	LogQAngle     *DmeLog[[3]float32]
+	LogQuaternion *DmeLog[[4]float32]
+	LogVMatrix    *DmeLog[[4][4]float32]
+	LogString     *DmeLog[string]
+	LogTime       *DmeLog[Time]
 }
 
 func parseChannel(e *internal.Element) *DmeChannel {
@@ -97,21 +167,80 @@ func parseChannel(e *internal.Element) *DmeChannel {
 		Name:          e.Name,
 		FromAttribute: e.Attributes["fromAttribute"].(string),
 		FromIndex:     e.Attributes["fromIndex"].(int32),
-		ToElement:     parseTransform(e.Attributes["toElement"].(*internal.Element)),
+		ToElement:     parseElementRef(e.Attributes["toElement"].(*internal.Element)),
 		ToAttribute:   e.Attributes["toAttribute"].(string),
 		ToIndex:       e.Attributes["toIndex"].(int32),
 	}
 	if log, ok := e.Attributes["log"].(*internal.Element); ok {
 		switch log.Type {
-		case "DmeQuaternionLog":
-			channel.LogQuaternion = parseLog[[4]float32](log)
+		case "DmeIntLog":
+			channel.LogInt = parseLog[int32](log)
+		case "DmeFloatLog":
+			channel.LogFloat = parseLog[float32](log)
+		case "DmeBoolLog":
+			channel.LogBool = parseLog[bool](log)
+		case "DmeColorLog":
+			channel.LogColor = parseLog[color.RGBA](log)
+		case "DmeVector2Log":
+			channel.LogVector2 = parseLog[[2]float32](log)
 		case "DmeVector3Log":
 			channel.LogVector3 = parseLog[[3]float32](log)
+		case "DmeVector4Log":
+			channel.LogVector4 = parseLog[[4]float32](log)
+		case "DmeQAngleLog":
+			channel.LogQAngle = parseLog[[3]float32](log)
+		case "DmeQuaternionLog":
+			channel.LogQuaternion = parseLog[[4]float32](log)
+		case "DmeVMatrixLog":
+			channel.LogVMatrix = parseLog[[4][4]float32](log)
+		case "DmeStringLog":
+			channel.LogString = parseLog[string](log)
+		case "DmeTimeLog":
+			channel.LogTime = parseLog[Time](log)
 		}
 	}
 	return channel
 }
 
+// Transform returns the transform the channel drives, or nil if its target
+// is not a DmeTransform.
+func (c *DmeChannel) Transform() *DmeTransform {
+	if c.ToElement == nil || c.ToElement.Type != "DmeTransform" {
+		return nil
+	}
+	return parseTransform(c.ToElement.e)
+}
+
+// IsPosition reports whether the channel drives the position of a transform.
+func (c *DmeChannel) IsPosition() bool {
+	return c.ToElement != nil && c.ToElement.Type == "DmeTransform" && c.ToAttribute == "position"
+}
+
+// IsOrientation reports whether the channel drives the orientation of a
+// transform.
+func (c *DmeChannel) IsOrientation() bool {
+	return c.ToElement != nil && c.ToElement.Type == "DmeTransform" && c.ToAttribute == "orientation"
+}
+
+// FlexWeightIndex returns the index of the flex weight the channel drives,
+// and false if it does not drive a flex weight array.
+func (c *DmeChannel) FlexWeightIndex() (int, bool) {
+	if c.ToElement == nil || c.ToAttribute != "flexWeights" {
+		return 0, false
+	}
+	return int(c.ToIndex), true
+}
+
+// Control returns the name of the combination input control the channel
+// drives, and false if it does not drive one. ToAttribute tells which value
+// of the control (value, leftValue or rightValue) is driven.
+func (c *DmeChannel) Control() (string, bool) {
+	if c.ToElement == nil || c.ToElement.Type != "DmeCombinationInputControl" {
+		return "", false
+	}
+	return c.ToElement.Name, true
+}
+
 func parseChannelList(e []*internal.Element) []*DmeChannel {
 	if e == nil {
 		return nil
@@ -126,44 +255,90 @@ func parseChannelList(e []*internal.Element) []*DmeChannel {
 // Log //
 
 type LogType interface {
-	[4]float32 | [3]float32
+	int32 | float32 | bool | color.RGBA | Time | string |
+		[2]float32 | [3]float32 | [4]float32 | [4][4]float32
 }
 
 type DmeLog[T LogType] struct {
 	Name            string
+	Type            string
 	Layers          []*DmeLogLayer[T]
+	CurveInfo       *DmeCurveInfo
 	UseDefaultValue bool
 	DefaultValue    T
+	// Bookmarks holds the bookmarked times of each component (x, y and z).
+	Bookmarks [3][]Time
 }
 
 func parseLog[T LogType](e *internal.Element) *DmeLog[T] {
 	if e == nil {
 		return nil
 	}
-	return &DmeLog[T]{
-		Name:            e.Name,
-		Layers:          parseLayerList[T](e.Attributes["layers"].([]*internal.Element)),
-		UseDefaultValue: e.Attributes["usedefaultvalue"].(bool),
-		DefaultValue:    e.Attributes["defaultvalue"].(T),
+	log := &DmeLog[T]{
+		Name:   e.Name,
+		Type:   e.Type,
+		Layers: parseLayerList[T](e.Attributes["layers"].([]*internal.Element)),
+	}
+	if curveInfo, ok := e.Attributes["curveinfo"].(*internal.Element); ok {
+		log.CurveInfo = parseCurveInfo(curveInfo)
+		for _, layer := range log.Layers {
+			if layer != nil {
+				layer.defaultCurveType = log.CurveInfo.DefaultCurveType
+			}
+		}
+	}
+	if bookmarks, ok := e.Attributes["bookmarks"]; ok {
+		times := parseTimes(bookmarks)
+		log.Bookmarks = [3][]Time{times, times, times}
+	}
+	for i, name := range []string{"bookmarksX", "bookmarksY", "bookmarksZ"} {
+		if bookmarks, ok := e.Attributes[name]; ok {
+			log.Bookmarks[i] = parseTimes(bookmarks)
+		}
+	}
+	if useDefaultValue, ok := e.Attributes["usedefaultvalue"].(bool); ok {
+		log.UseDefaultValue = useDefaultValue
 	}
+	if defaultValue, ok := e.Attributes["defaultvalue"].(T); ok {
+		log.DefaultValue = defaultValue
+	} else if _, ok := any(log.DefaultValue).(Time); ok {
+		log.DefaultValue = any(parseTime(e.Attributes["defaultvalue"])).(T)
+	}
+	return log
 }
 
 // Layer //
 type DmeLogLayer[T LogType] struct {
-	Name   string
-	Times  []int32
-	Values []T
+	Name       string
+	Type       string
+	Times      []Time
+	CurveTypes []CurveType
+	Values     []T
+
+	defaultCurveType CurveType
 }
 
 func parseLayer[T LogType](e *internal.Element) *DmeLogLayer[T] {
 	if e == nil {
 		return nil
 	}
-	return &DmeLogLayer[T]{
-		Name:   e.Name,
-		Times:  e.Attributes["times"].([]int32),
-		Values: e.Attributes["values"].([]T),
+	layer := &DmeLogLayer[T]{
+		Name:  e.Name,
+		Type:  e.Type,
+		Times: parseTimes(e.Attributes["times"]),
+	}
+	if curveTypes, ok := e.Attributes["curvetypes"].([]int32); ok && len(curveTypes) > 0 {
+		layer.CurveTypes = make([]CurveType, len(curveTypes))
+		for i, c := range curveTypes {
+			layer.CurveTypes[i] = CurveType(c)
+		}
+	}
+	if values, ok := e.Attributes["values"].([]T); ok {
+		layer.Values = values
+	} else if _, ok := any(layer.Values).([]Time); ok {
+		layer.Values = any(parseTimes(e.Attributes["values"])).([]T)
 	}
+	return layer
 }
 
 func parseLayerList[T LogType](e []*internal.Element) []*DmeLogLayer[T] {
@@ -0,0 +1,126 @@
+package dmx
+
+import "github.com/aoisensi/darkseer/dmx/internal"
+
+type DmeCombinationOperator struct {
+	Name          string
+	Controls      []*DmeCombinationInputControl
+	ControlValues [][3]float32
+	Dominators    []*DmeCombinationDominationRule
+}
+
+func parseCombinationOperator(e *internal.Element) *DmeCombinationOperator {
+	if e == nil {
+		return nil
+	}
+	if e.Type != "DmeCombinationOperator" {
+		panic("dmx: invalid element type")
+	}
+	result := &DmeCombinationOperator{Name: e.Name}
+	if controls, ok := e.Attributes["controls"].([]*internal.Element); ok {
+		result.Controls = parseCombinationInputControlList(controls)
+	}
+	if controlValues, ok := e.Attributes["controlValues"].([][3]float32); ok {
+		result.ControlValues = controlValues
+	}
+	if dominators, ok := e.Attributes["dominators"].([]*internal.Element); ok {
+		result.Dominators = parseCombinationDominationRuleList(dominators)
+	}
+	return result
+}
+
+// Control returns the control named name, or nil if there is none.
+func (o *DmeCombinationOperator) Control(name string) *DmeCombinationInputControl {
+	for _, control := range o.Controls {
+		if control != nil && control.Name == name {
+			return control
+		}
+	}
+	return nil
+}
+
+type DmeCombinationInputControl struct {
+	Name            string
+	RawControlNames []string
+	Stereo          bool
+	Eyelid          bool
+	FlexMin         float32
+	FlexMax         float32
+	WrinkleScales   []float32
+}
+
+func parseCombinationInputControl(e *internal.Element) *DmeCombinationInputControl {
+	if e == nil {
+		return nil
+	}
+	if e.Type != "DmeCombinationInputControl" {
+		panic("dmx: invalid element type")
+	}
+	result := &DmeCombinationInputControl{
+		Name:    e.Name,
+		FlexMax: 1,
+	}
+	if rawControlNames, ok := e.Attributes["rawControlNames"].([]string); ok {
+		result.RawControlNames = rawControlNames
+	}
+	if stereo, ok := e.Attributes["stereo"].(bool); ok {
+		result.Stereo = stereo
+	}
+	if eyelid, ok := e.Attributes["eyelid"].(bool); ok {
+		result.Eyelid = eyelid
+	}
+	if flexMin, ok := e.Attributes["flexMin"].(float32); ok {
+		result.FlexMin = flexMin
+	}
+	if flexMax, ok := e.Attributes["flexMax"].(float32); ok {
+		result.FlexMax = flexMax
+	}
+	if wrinkleScales, ok := e.Attributes["wrinkleScales"].([]float32); ok {
+		result.WrinkleScales = wrinkleScales
+	}
+	return result
+}
+
+func parseCombinationInputControlList(e []*internal.Element) []*DmeCombinationInputControl {
+	if e == nil {
+		return nil
+	}
+	list := make([]*DmeCombinationInputControl, len(e))
+	for i, v := range e {
+		list[i] = parseCombinationInputControl(v)
+	}
+	return list
+}
+
+type DmeCombinationDominationRule struct {
+	Dominators []string
+	Suppressed []string
+}
+
+func parseCombinationDominationRule(e *internal.Element) *DmeCombinationDominationRule {
+	if e == nil {
+		return nil
+	}
+	if e.Type != "DmeCombinationDominationRule" {
+		panic("dmx: invalid element type")
+	}
+	result := &DmeCombinationDominationRule{}
+	if dominators, ok := e.Attributes["dominators"].([]string); ok {
+		result.Dominators = dominators
+	}
+	if suppressed, ok := e.Attributes["suppressed"].([]string); ok {
+		result.Suppressed = suppressed
+	}
+	return result
+}
+
+func parseCombinationDominationRuleList(e []*internal.Element) []*DmeCombinationDominationRule {
+	if e == nil {
+		return nil
+	}
+	list := make([]*DmeCombinationDominationRule, len(e))
+	for i, v := range e {
+		list[i] = parseCombinationDominationRule(v)
+	}
+	return list
+}
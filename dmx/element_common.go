@@ -7,6 +7,7 @@ type DmeDag struct {
 	Transform  *DmeTransform
 	Visible    bool
 	Children   []IDag
+	Parent     IDag
 	Mesh       *DmeMesh
 	Attachment *DmeAttachment
 }
@@ -15,6 +16,8 @@ func (d *DmeDag) Dag() *DmeDag {
 	return d
 }
 
+// IDag is implemented by every node of a DAG. All node types share their
+// name, transform, children and parent through the DmeDag returned by Dag.
 type IDag interface {
 	Dag() *DmeDag
 }
@@ -32,6 +35,8 @@ func parseDag(e *internal.Element) IDag {
 		return parseAttachment(e)
 	case "DmeMesh":
 		return parseMesh(e)
+	case "DmeModel":
+		return parseModel(e)
 	case "DmeDag":
 		return parseOnlyDag(e)
 	}
@@ -41,50 +46,55 @@ func parseOnlyDag(e *internal.Element) *DmeDag {
 	if e == nil {
 		return nil
 	}
-	result := &DmeDag{
-		Name:    e.Name,
-		Visible: e.Attributes["visible"].(bool),
+	result := &DmeDag{}
+	parseDagInto(result, result, e)
+	return result
+}
+
+// parseDagInto fills d, the DmeDag embedded in self, from e. Children and
+// shapes of e get self as their parent.
+func parseDagInto(d *DmeDag, self IDag, e *internal.Element) {
+	d.Name = e.Name
+	d.Visible = true
+	if visible, ok := e.Attributes["visible"].(bool); ok {
+		d.Visible = visible
 	}
 	if transform, ok := e.Attributes["transform"]; ok && transform != nil {
-		result.Transform = parseTransform(transform.(*internal.Element))
-	}
-	if children, ok := e.Attributes["children"]; ok && children != nil {
-		eChildlen := children.([]*internal.Element)
-		children := make([]IDag, len(eChildlen))
-		for i, c := range eChildlen {
-			children[i] = parseDag(c)
-		}
-		result.Children = children
+		d.Transform = parseTransform(transform.(*internal.Element))
 	}
+	d.Children = parseDagList(e.Attributes["children"], self)
 	if shape, ok := e.Attributes["shape"]; ok && shape != nil {
 		shape := shape.(*internal.Element)
 		switch shape.Type {
 		case "DmeMesh":
-			result.Mesh = parseMesh(shape)
+			d.Mesh = parseMesh(shape)
+			d.Mesh.Parent = self
 		case "DmeAttachment":
-			result.Attachment = parseAttachment(shape)
+			d.Attachment = parseAttachment(shape)
+			d.Attachment.Parent = self
 		}
 	}
-	return result
 }
 
-func parseDagList(e any) []IDag {
+func parseDagList(e any, parent IDag) []IDag {
 	if e == nil {
 		return nil
 	}
 	eChildlen := e.([]*internal.Element)
-	children := make([]IDag, len(eChildlen))
-	for i, c := range eChildlen {
-		children[i] = parseDag(c)
+	children := make([]IDag, 0, len(eChildlen))
+	for _, c := range eChildlen {
+		child := parseDag(c)
+		if child == nil {
+			continue
+		}
+		child.Dag().Parent = parent
+		children = append(children, child)
 	}
 	return children
 }
 
 type DmeJoint struct {
 	*DmeDag
-	Transform            *DmeTransform
-	Visible              bool
-	Children             []IDag
 	LockInfluenceWeights bool
 }
 
@@ -98,17 +108,8 @@ func parseJoint(e *internal.Element) *DmeJoint {
 	if e.Type != "DmeJoint" {
 		panic("dmx: invalid element type")
 	}
-	eChildlen := e.Attributes["children"].([]*internal.Element)
-	children := make([]IDag, len(eChildlen))
-	for i, c := range eChildlen {
-		children[i] = parseDag(c)
-	}
-	joint := &DmeJoint{
-		DmeDag:    parseOnlyDag(e),
-		Transform: parseTransform(e.Attributes["transform"].(*internal.Element)),
-		Visible:   e.Attributes["visible"].(bool),
-		Children:  children,
-	}
+	joint := &DmeJoint{DmeDag: &DmeDag{}}
+	parseDagInto(joint.DmeDag, joint, e)
 	if lockInfluenceWeights, ok := e.Attributes["lockInfluenceWeights"]; ok {
 		joint.LockInfluenceWeights = lockInfluenceWeights.(bool)
 	}
@@ -116,6 +117,7 @@ func parseJoint(e *internal.Element) *DmeJoint {
 }
 
 type DmeTransformList struct {
+	Name       string
 	Transforms []*DmeTransform
 }
 
@@ -132,6 +134,7 @@ func parseTransformList(e *internal.Element) *DmeTransformList {
 		transforms[i] = parseTransform(t)
 	}
 	return &DmeTransformList{
+		Name:       e.Name,
 		Transforms: transforms,
 	}
 }
@@ -140,6 +143,7 @@ type DmeTransform struct {
 	Name        string
 	Position    [3]float32
 	Orientation [4]float32
+	Scale       [3]float32
 }
 
 func parseTransform(e *internal.Element) *DmeTransform {
@@ -149,9 +153,17 @@ func parseTransform(e *internal.Element) *DmeTransform {
 	if e.Type != "DmeTransform" {
 		panic("dmx: invalid element type")
 	}
-	return &DmeTransform{
+	transform := &DmeTransform{
 		Name:        e.Name,
 		Position:    e.Attributes["position"].([3]float32),
 		Orientation: e.Attributes["orientation"].([4]float32),
+		Scale:       [3]float32{1, 1, 1},
+	}
+	switch scale := e.Attributes["scale"].(type) {
+	case float32:
+		transform.Scale = [3]float32{scale, scale, scale}
+	case [3]float32:
+		transform.Scale = scale
 	}
+	return transform
 }
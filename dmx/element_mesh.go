@@ -3,29 +3,49 @@ package dmx
 import "github.com/aoisensi/darkseer/dmx/internal"
 
 type DmeModelRoot struct {
-	Model    DmeModel
-	Skeleton DmeModel
-	// TODO CombinationOperator DmeCombinationOperator
+	Name                string
+	Model               *DmeModel
+	Skeleton            *DmeModel
+	CombinationOperator *DmeCombinationOperator
 }
 
 func parseModelRoot(e *internal.Element) *DmeModelRoot {
 	if e == nil {
 		return nil
 	}
-	if e.Type != "DmeModelRoot" {
+	if e.Type != "DmeModelRoot" && e.Type != "DmElement" {
 		panic("dmx: invalid element type")
 	}
-	return &DmeModelRoot{
-		Model:    *parseModel(e.Attributes["model"].(*internal.Element)),
-		Skeleton: *parseModel(e.Attributes["skeleton"].(*internal.Element)),
+	root := &DmeModelRoot{Name: e.Name}
+	model := findElementAttribute(e, "DmeModel", "model")
+	if model != nil {
+		root.Model = parseModel(model)
 	}
+	// Most files point both at the same element, which should stay a
+	// single DmeModel.
+	if skeleton := findElementAttribute(e, "DmeModel", "skeleton"); skeleton == model {
+		root.Skeleton = root.Model
+	} else if skeleton != nil {
+		root.Skeleton = parseModel(skeleton)
+	}
+	if root.Model == nil {
+		root.Model = root.Skeleton
+	}
+	if root.Skeleton == nil {
+		root.Skeleton = root.Model
+	}
+	if operator := findElementAttribute(e, "DmeCombinationOperator", "combinationOperator"); operator != nil {
+		root.CombinationOperator = parseCombinationOperator(operator)
+	}
+	return root
 }
 
 type DmeModel struct {
-	Name            string
-	Visible         bool
-	Children        []IDag
+	*DmeDag
 	JointTransforms []*DmeTransform
+	// BaseStates holds saved poses of the joints, such as the bind pose,
+	// with a transform for each of JointTransforms.
+	BaseStates []*DmeTransformList
 }
 
 func parseModel(e *internal.Element) *DmeModel {
@@ -35,19 +55,35 @@ func parseModel(e *internal.Element) *DmeModel {
 	if e.Type != "DmeModel" {
 		panic("dmx: invalid element type")
 	}
-	model := &DmeModel{
-		Name:     e.Name,
-		Visible:  e.Attributes["visible"].(bool),
-		Children: parseDagList(e.Attributes["children"]),
-	}
+	model := &DmeModel{DmeDag: &DmeDag{}}
+	parseDagInto(model.DmeDag, model, e)
 	if e.Attributes["jointTransforms"] != nil {
 		for _, e := range e.Attributes["jointTransforms"].([]*internal.Element) {
 			model.JointTransforms = append(model.JointTransforms, parseTransform(e))
 		}
 	}
+	if baseStates, ok := e.Attributes["baseStates"].([]*internal.Element); ok {
+		for _, e := range baseStates {
+			model.BaseStates = append(model.BaseStates, parseTransformList(e))
+		}
+	}
 	return model
 }
 
+// BindState returns the base state named "bind", or the first base state if
+// none is, or nil if the model has none.
+func (m *DmeModel) BindState() *DmeTransformList {
+	for _, state := range m.BaseStates {
+		if state != nil && state.Name == "bind" {
+			return state
+		}
+	}
+	if len(m.BaseStates) > 0 {
+		return m.BaseStates[0]
+	}
+	return nil
+}
+
 func parseModelList(e []*internal.Element) []*DmeModel {
 	if e == nil {
 		return nil
@@ -61,9 +97,8 @@ func parseModelList(e []*internal.Element) []*DmeModel {
 
 type DmeAttachment struct {
 	*DmeDag
-	Visible bool
-	// IsRigid        bool
-	// IsWorldAligned bool
+	IsRigid        bool
+	IsWorldAligned bool
 }
 
 func parseAttachment(e *internal.Element) *DmeAttachment {
@@ -73,12 +108,15 @@ func parseAttachment(e *internal.Element) *DmeAttachment {
 	if e.Type != "DmeAttachment" {
 		panic("dmx: invalid element type")
 	}
-	return &DmeAttachment{
-		DmeDag:  parseOnlyDag(e),
-		Visible: e.Attributes["visible"].(bool),
-		// IsRigid:        e.Attributes["isrigid"].(bool),
-		// IsWorldAligned: e.Attributes["isworldaligned"].(bool),
+	attachment := &DmeAttachment{DmeDag: &DmeDag{}}
+	parseDagInto(attachment.DmeDag, attachment, e)
+	if isRigid, ok := e.Attributes["isRigid"].(bool); ok {
+		attachment.IsRigid = isRigid
+	}
+	if isWorldAligned, ok := e.Attributes["isWorldAligned"].(bool); ok {
+		attachment.IsWorldAligned = isWorldAligned
 	}
+	return attachment
 }
 
 func parseAttachmentList(e []*internal.Element) []*DmeAttachment {
@@ -94,10 +132,9 @@ func parseAttachmentList(e []*internal.Element) []*DmeAttachment {
 
 type DmeMesh struct {
 	*DmeDag
-	Visible      bool
 	CurrentState *DmeVertexData
 	BaseStates   []*DmeVertexData
-	DeltaStates  []*DmeVertexData
+	DeltaStates  []*DmeVertexDeltaData
 	FaceSets     []*DmeFaceSet
 }
 
@@ -108,14 +145,37 @@ func parseMesh(e *internal.Element) *DmeMesh {
 	if e.Type != "DmeMesh" {
 		panic("dmx: invalid element type")
 	}
-	return &DmeMesh{
-		DmeDag:       parseOnlyDag(e),
-		Visible:      e.Attributes["visible"].(bool),
+	mesh := &DmeMesh{
+		DmeDag:       &DmeDag{},
 		CurrentState: parseVertexData(e.Attributes["currentState"].(*internal.Element)),
 		BaseStates:   parseVertexDataList(e.Attributes["baseStates"].([]*internal.Element)),
-		DeltaStates:  parseVertexDataList(e.Attributes["deltaStates"].([]*internal.Element)),
+		DeltaStates:  parseVertexDeltaDataList(e.Attributes["deltaStates"].([]*internal.Element)),
 		FaceSets:     parseFaceSetList(e.Attributes["faceSets"].([]*internal.Element)),
 	}
+	parseDagInto(mesh.DmeDag, mesh, e)
+	return mesh
+}
+
+// BaseState returns the base state named name (e.g. "bind"), or nil if the
+// mesh has no such state.
+func (m *DmeMesh) BaseState(name string) *DmeVertexData {
+	for _, state := range m.BaseStates {
+		if state != nil && state.Name == name {
+			return state
+		}
+	}
+	return nil
+}
+
+// DeltaState returns the delta state named name, or nil if the mesh has no
+// such state.
+func (m *DmeMesh) DeltaState(name string) *DmeVertexDeltaData {
+	for _, state := range m.DeltaStates {
+		if state != nil && state.Name == name {
+			return state
+		}
+	}
+	return nil
 }
 
 func parseMeshList(e []*internal.Element) []*DmeMesh {
@@ -130,6 +190,7 @@ func parseMeshList(e []*internal.Element) []*DmeMesh {
 }
 
 type DmeVertexData struct {
+	Name                      string
 	VertexFormat              []string
 	JointCount                int32
 	Positions                 [][3]float32
@@ -152,6 +213,7 @@ func parseVertexData(e *internal.Element) *DmeVertexData {
 		panic("dmx: invalid element type")
 	}
 	result := &DmeVertexData{
+		Name:                      e.Name,
 		VertexFormat:              e.Attributes["vertexFormat"].([]string),
 		JointCount:                e.Attributes["jointCount"].(int32),
 		Positions:                 e.Attributes["positions"].([][3]float32),
@@ -215,16 +277,20 @@ func parseMaterial(e *internal.Element) *DmeMaterial {
 	}
 }
 
+// DmeVertexDeltaData is a delta state of a mesh: offsets from the base
+// state for the positions and normals it lists. PositionIndices and
+// NormalsIndices index the positions and normals of the base state.
 type DmeVertexDeltaData struct {
+	Name             string
 	VertexFormat     []string
 	FlipVCoordinates bool
 	Corrected        bool
 	Positions        [][3]float32
-	PositionIndices  []int
+	PositionIndices  []int32
 	Normals          [][3]float32
-	NormalsIndices   []int
+	NormalsIndices   []int32
 	Wrinkle          []float32
-	WrinkleIndices   []int
+	WrinkleIndices   []int32
 }
 
 func parseVertexDeltaData(e *internal.Element) *DmeVertexDeltaData {
@@ -234,17 +300,19 @@ func parseVertexDeltaData(e *internal.Element) *DmeVertexDeltaData {
 	if e.Type != "DmeVertexDeltaData" {
 		panic("dmx: invalid element type")
 	}
-	return &DmeVertexDeltaData{
-		VertexFormat:     e.Attributes["vertexformat"].([]string),
-		FlipVCoordinates: e.Attributes["flipvcoordinates"].(bool),
-		Corrected:        e.Attributes["corrected"].(bool),
-		Positions:        e.Attributes["positions"].([][3]float32),
-		PositionIndices:  e.Attributes["positionindices"].([]int),
-		Normals:          e.Attributes["normals"].([][3]float32),
-		NormalsIndices:   e.Attributes["normalsindices"].([]int),
-		Wrinkle:          e.Attributes["wrinkle"].([]float32),
-		WrinkleIndices:   e.Attributes["wrinkleindices"].([]int),
-	}
+	result := &DmeVertexDeltaData{
+		Name: e.Name,
+	}
+	result.VertexFormat, _ = e.Attributes["vertexFormat"].([]string)
+	result.FlipVCoordinates, _ = e.Attributes["flipVCoordinates"].(bool)
+	result.Corrected, _ = e.Attributes["corrected"].(bool)
+	result.Positions, _ = e.Attributes["positions"].([][3]float32)
+	result.PositionIndices, _ = e.Attributes["positionsIndices"].([]int32)
+	result.Normals, _ = e.Attributes["normals"].([][3]float32)
+	result.NormalsIndices, _ = e.Attributes["normalsIndices"].([]int32)
+	result.Wrinkle, _ = e.Attributes["wrinkle"].([]float32)
+	result.WrinkleIndices, _ = e.Attributes["wrinkleIndices"].([]int32)
+	return result
 }
 
 func parseVertexDeltaDataList(e []*internal.Element) []*DmeVertexDeltaData {
@@ -0,0 +1,167 @@
+package dmx
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/aoisensi/darkseer/dmx/dmxmath"
+)
+
+// Value returns the value of the log at time t. The first layer holds its
+// first and last values outside its keys; every later layer overrides the
+// ones below it between its first and last key. An empty layer holds
+// DefaultValue if UseDefaultValue is set and is skipped otherwise. If no
+// layer has a value, DefaultValue is returned.
+func (l *DmeLog[T]) Value(t Time) T {
+	value := l.DefaultValue
+	for i, layer := range l.Layers {
+		if layer == nil {
+			continue
+		}
+		if len(layer.Times) == 0 || len(layer.Times) != len(layer.Values) {
+			if l.UseDefaultValue {
+				value = l.DefaultValue
+			}
+			continue
+		}
+		if i == 0 {
+			value = layer.Clamp(t)
+		} else if v, ok := layer.Value(t); ok {
+			value = v
+		}
+	}
+	return value
+}
+
+// Value returns the value of the layer at time t, interpolating between the
+// surrounding keys with the outgoing curve type of the earlier key. Linear
+// and ease curves interpolate numbers, vectors and colors linearly and
+// quaternions and angles by slerp, hold curves keep the earlier value and
+// spline curves follow a Catmull-Rom spline. Discrete types always hold. It
+// reports false if the layer has no keys or t is outside them.
+func (l *DmeLogLayer[T]) Value(t Time) (T, bool) {
+	var zero T
+	n := len(l.Times)
+	if n == 0 || n != len(l.Values) {
+		return zero, false
+	}
+	if t < l.Times[0] || t > l.Times[n-1] {
+		return zero, false
+	}
+	return l.Clamp(t), true
+}
+
+// Clamp is like Value but holds the first and last values outside the
+// layer's key range. It panics if the layer has no keys.
+func (l *DmeLogLayer[T]) Clamp(t Time) T {
+	n := len(l.Times)
+	i := sort.Search(n, func(i int) bool { return l.Times[i] > t })
+	if i == 0 {
+		return l.Values[0]
+	}
+	if i == n {
+		return l.Values[n-1]
+	}
+	t0, t1 := l.Times[i-1], l.Times[i]
+	if t1 == t0 {
+		return l.Values[i]
+	}
+	s := float32(t-t0) / float32(t1-t0)
+	switch kind := l.curveType(i - 1).Out().kind(); kind {
+	case curveStep:
+		return l.Values[i-1]
+	case curveSpline:
+		if v, ok := splineValue(l, i, s); ok {
+			return v
+		}
+	default:
+		s = kind.ease(s)
+	}
+	return interpolate(l.Values[i-1], l.Values[i], s, l.Type)
+}
+
+// Trim returns the layer without its keys before from. A key at from with
+// the layer's value there replaces them, so the layer still changes the
+// same way from then on. It returns l itself if no key is before from.
+func (l *DmeLogLayer[T]) Trim(from Time) *DmeLogLayer[T] {
+	n := len(l.Times)
+	i := sort.Search(n, func(i int) bool { return l.Times[i] >= from })
+	if i == 0 || n != len(l.Values) {
+		return l
+	}
+	trimmed := *l
+	trimmed.Times = append([]Time{from}, l.Times[i:]...)
+	trimmed.Values = append([]T{l.Clamp(from)}, l.Values[i:]...)
+	if i < len(l.CurveTypes) {
+		trimmed.CurveTypes = append([]CurveType{l.curveType(i - 1)}, l.CurveTypes[i:]...)
+	} else {
+		trimmed.CurveTypes = nil
+	}
+	if i < n && l.Times[i] == from {
+		trimmed.Times = trimmed.Times[1:]
+		trimmed.Values = trimmed.Values[1:]
+		if len(trimmed.CurveTypes) > 0 {
+			trimmed.CurveTypes = trimmed.CurveTypes[1:]
+		}
+	}
+	return &trimmed
+}
+
+// curveType returns the curve type of key i, falling back to the log's
+// default curve type if the layer has none.
+func (l *DmeLogLayer[T]) curveType(i int) CurveType {
+	if i < len(l.CurveTypes) {
+		return l.CurveTypes[i]
+	}
+	return l.defaultCurveType
+}
+
+// interpolate blends from a to b by t. Logs of discrete values (ints, bools
+// and strings) hold a until b is reached.
+func interpolate[T LogType](a, b T, t float32, layerType string) T {
+	var result any
+	switch a := any(a).(type) {
+	case int32, bool, string:
+		if t < 1 {
+			return any(a).(T)
+		}
+		return b
+	case float32:
+		b := any(b).(float32)
+		result = a + (b-a)*t
+	case Time:
+		b := any(b).(Time)
+		result = a + Time(math.Round(float64(b-a)*float64(t)))
+	case color.RGBA:
+		b := any(b).(color.RGBA)
+		lerp := func(x, y uint8) uint8 {
+			return uint8(math.Round(float64(x) + (float64(y)-float64(x))*float64(t)))
+		}
+		result = color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+	case [2]float32:
+		result = [2]float32(dmxmath.Vec2(a).Lerp(any(b).([2]float32), t))
+	case [3]float32:
+		if layerType == "DmeQAngleLogLayer" {
+			qa := dmxmath.QuatFromQAngle(a)
+			qb := dmxmath.QuatFromQAngle(any(b).([3]float32))
+			result = [3]float32(qa.Slerp(qb, t).QAngle())
+		} else {
+			result = [3]float32(dmxmath.Vec3(a).Lerp(any(b).([3]float32), t))
+		}
+	case [4]float32:
+		if layerType == "DmeVector4LogLayer" {
+			result = [4]float32(dmxmath.Vec4(a).Lerp(any(b).([4]float32), t))
+		} else {
+			result = [4]float32(dmxmath.Quat(a).Slerp(any(b).([4]float32), t))
+		}
+	case [4][4]float32:
+		ta, ra, sa := dmxmath.Mat4FromVMatrix(a).Decompose()
+		tb, rb, sb := dmxmath.Mat4FromVMatrix(any(b).([4][4]float32)).Decompose()
+		m := dmxmath.Compose(ta.Lerp(tb, t), ra.Slerp(rb, t), sa.Lerp(sb, t))
+		result = m.VMatrix()
+	default:
+		panic("unreachable")
+	}
+	return result.(T)
+}
@@ -0,0 +1,130 @@
+package dmx
+
+import "github.com/aoisensi/darkseer/dmx/dmxmath"
+
+// JointPose is the transform of one joint in a Pose.
+type JointPose struct {
+	Joint       *DmeJoint
+	Position    [3]float32
+	Orientation [4]float32
+	Scale       [3]float32
+	Local       dmxmath.Mat4
+	World       dmxmath.Mat4
+}
+
+// Pose is a skeleton posed at one point in time.
+type Pose struct {
+	// Joints lists every joint of the skeleton, parents before children.
+	Joints []*JointPose
+	byName map[string]*JointPose
+}
+
+// Joint returns the pose of the joint whose name or transform name is name,
+// or nil if there is none.
+func (p *Pose) Joint(name string) *JointPose {
+	return p.byName[name]
+}
+
+// EvaluatePose poses skeleton at time t, counted from the start of clip.
+// Channels are matched to joints by the name of the transform they drive;
+// joints and other DAG nodes without channels keep their rest transform. A
+// nil clip gives the rest pose.
+func EvaluatePose(skeleton *DmeModel, clip *DmeChannelsClip, t Time) *Pose {
+	return evaluatePose(skeleton, nil, clip, t)
+}
+
+// BindPose poses skeleton in the bind state of the model, the pose its
+// meshes were authored in. Joints the bind state does not list keep their
+// rest transform. It returns nil if the model has no bind state.
+func BindPose(skeleton *DmeModel) *Pose {
+	if skeleton == nil {
+		return nil
+	}
+	state := skeleton.BindState()
+	if state == nil {
+		return nil
+	}
+	transforms := make(map[string]*DmeTransform)
+	for _, transform := range state.Transforms {
+		if transform != nil {
+			transforms[transform.Name] = transform
+		}
+	}
+	return evaluatePose(skeleton, transforms, nil, 0)
+}
+
+// evaluatePose is EvaluatePose with the rest transforms of the transforms
+// named in rest replaced.
+func evaluatePose(skeleton *DmeModel, rest map[string]*DmeTransform, clip *DmeChannelsClip, t Time) *Pose {
+	pose := &Pose{byName: make(map[string]*JointPose)}
+	if skeleton == nil {
+		return pose
+	}
+	var channels map[string][]*DmeChannel
+	logTime := t
+	if clip != nil {
+		channels = make(map[string][]*DmeChannel)
+		for _, channel := range clip.Channels {
+			if channel == nil || channel.ToElement == nil || channel.ToElement.Type != "DmeTransform" {
+				continue
+			}
+			channels[channel.ToElement.Name] = append(channels[channel.ToElement.Name], channel)
+		}
+		logTime = clip.LogTime(t)
+	}
+
+	var walk func(node IDag, parent dmxmath.Mat4)
+	walk = func(node IDag, parent dmxmath.Mat4) {
+		dag := node.Dag()
+		position, orientation, scale := [3]float32{}, [4]float32{0, 0, 0, 1}, [3]float32{1, 1, 1}
+		name := dag.Name
+		if dag.Transform != nil {
+			name = dag.Transform.Name
+			transform := dag.Transform
+			if override, ok := rest[name]; ok {
+				transform = override
+			}
+			position, orientation, scale = transform.Position, transform.Orientation, transform.Scale
+		}
+		for _, channel := range channels[name] {
+			switch {
+			case channel.IsPosition() && channel.LogVector3 != nil:
+				position = channel.LogVector3.Value(logTime)
+			case channel.IsOrientation() && channel.LogQuaternion != nil:
+				orientation = channel.LogQuaternion.Value(logTime)
+			case channel.ToAttribute == "scale" && channel.LogVector3 != nil:
+				scale = channel.LogVector3.Value(logTime)
+			case channel.ToAttribute == "scale" && channel.LogFloat != nil:
+				s := channel.LogFloat.Value(logTime)
+				scale = [3]float32{s, s, s}
+			}
+		}
+		local := dmxmath.Compose(position, orientation, scale)
+		world := parent.Mul(local)
+		if joint, ok := node.(*DmeJoint); ok {
+			jointPose := &JointPose{
+				Joint:       joint,
+				Position:    position,
+				Orientation: orientation,
+				Scale:       scale,
+				Local:       local,
+				World:       world,
+			}
+			pose.Joints = append(pose.Joints, jointPose)
+			pose.byName[joint.Name] = jointPose
+			pose.byName[name] = jointPose
+		}
+		for _, child := range dag.Children {
+			if child != nil {
+				walk(child, world)
+			}
+		}
+	}
+	root := skeleton.Transform.Matrix()
+	for _, child := range skeleton.Children {
+		if child != nil {
+			walk(child, root)
+		}
+	}
+	return pose
+}
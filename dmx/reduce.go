@@ -0,0 +1,103 @@
+package dmx
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/aoisensi/darkseer/dmx/dmxmath"
+)
+
+// Reduce returns a copy of the layer without the keys that linear
+// interpolation between the remaining keys reproduces within tolerance.
+// The tolerance is an angle in degrees for quaternion and QAngle layers and
+// a distance for the other numeric and vector layers. Keys of other types
+// are only dropped where they repeat the value before them. The first and
+// last keys are always kept, and the result interpolates linearly.
+func (l *DmeLogLayer[T]) Reduce(tolerance float64) *DmeLogLayer[T] {
+	reduced := *l
+	reduced.CurveTypes = nil
+	reduced.defaultCurveType = MakeCurveType(InterpolateLinear, InterpolateLinear)
+	n := len(l.Times)
+	if n <= 2 || n != len(l.Values) {
+		return &reduced
+	}
+
+	// Ramer-Douglas-Peucker over the key times.
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+	type segment struct{ a, b int }
+	stack := []segment{{0, n - 1}}
+	for len(stack) > 0 {
+		seg := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		worst, worstErr := -1, tolerance
+		t0, t1 := l.Times[seg.a], l.Times[seg.b]
+		for i := seg.a + 1; i < seg.b; i++ {
+			var s float32
+			if t1 != t0 {
+				s = float32(l.Times[i]-t0) / float32(t1-t0)
+			}
+			predicted := interpolate(l.Values[seg.a], l.Values[seg.b], s, l.Type)
+			if err := keyError(predicted, l.Values[i], l.Type); err > worstErr {
+				worst, worstErr = i, err
+			}
+		}
+		if worst < 0 {
+			continue
+		}
+		keep[worst] = true
+		stack = append(stack, segment{seg.a, worst}, segment{worst, seg.b})
+	}
+
+	reduced.Times = nil
+	reduced.Values = nil
+	for i := range keep {
+		if keep[i] {
+			reduced.Times = append(reduced.Times, l.Times[i])
+			reduced.Values = append(reduced.Values, l.Values[i])
+		}
+	}
+	return &reduced
+}
+
+// keyError measures how far a is from b in the units Reduce documents.
+func keyError[T LogType](a, b T, layerType string) float64 {
+	switch a := any(a).(type) {
+	case float32:
+		return math.Abs(float64(a - any(b).(float32)))
+	case int32:
+		return math.Abs(float64(a - any(b).(int32)))
+	case Time:
+		return math.Abs((a - any(b).(Time)).Seconds())
+	case [2]float32:
+		d := dmxmath.Vec2(a).Sub(any(b).([2]float32))
+		return math.Hypot(float64(d[0]), float64(d[1]))
+	case [3]float32:
+		if layerType == "DmeQAngleLogLayer" {
+			qa := dmxmath.QuatFromQAngle(a)
+			qb := dmxmath.QuatFromQAngle(any(b).([3]float32))
+			return quatAngle(qa, qb)
+		}
+		return float64(dmxmath.Vec3(a).Sub(any(b).([3]float32)).Len())
+	case [4]float32:
+		if layerType == "DmeVector4LogLayer" {
+			d := dmxmath.Vec4(a).Sub(any(b).([4]float32))
+			return math.Sqrt(float64(d.Dot(d)))
+		}
+		return quatAngle(dmxmath.Quat(a), any(b).([4]float32))
+	case color.RGBA:
+		b := any(b).(color.RGBA)
+		d := func(x, y uint8) float64 { return float64(x) - float64(y) }
+		return math.Sqrt(d(a.R, b.R)*d(a.R, b.R) + d(a.G, b.G)*d(a.G, b.G) + d(a.B, b.B)*d(a.B, b.B) + d(a.A, b.A)*d(a.A, b.A))
+	default:
+		if any(a) == any(b) {
+			return 0
+		}
+		return math.Inf(1)
+	}
+}
+
+// quatAngle returns the angle in degrees of the rotation between a and b.
+func quatAngle(a, b dmxmath.Quat) float64 {
+	return float64(a.Conjugate().Mul(b).Angle()) * 180 / math.Pi
+}
@@ -0,0 +1,143 @@
+package dmx
+
+import (
+	"math"
+
+	"github.com/aoisensi/darkseer/dmx/dmxmath"
+)
+
+// Resample returns a copy of the clip in which every channel's log has a
+// single layer with a key every 1/rate seconds, from the start to the end
+// of the clip. Clips without a duration cover the keys of all their
+// channels instead.
+func (c *DmeChannelsClip) Resample(rate float64) *DmeChannelsClip {
+	start, end := Time(0), c.Duration()
+	if end <= 0 {
+		first, last, ok := c.keyRange()
+		if !ok {
+			return c
+		}
+		start, end = c.LocalTime(first), c.LocalTime(last)
+	}
+	var times []Time
+	frames := int(math.Ceil((end - start).Frames(rate)))
+	for i := 0; i <= frames; i++ {
+		local := start + TimeFromFrames(float64(i), rate)
+		if local > end {
+			local = end
+		}
+		times = append(times, c.LogTime(local))
+	}
+
+	clip := *c
+	clip.Channels = make([]*DmeChannel, len(c.Channels))
+	for i, channel := range c.Channels {
+		if channel == nil {
+			continue
+		}
+		resampled := *channel
+		resampled.LogInt = resampleLog(channel.LogInt, times)
+		resampled.LogFloat = resampleLog(channel.LogFloat, times)
+		resampled.LogBool = resampleLog(channel.LogBool, times)
+		resampled.LogColor = resampleLog(channel.LogColor, times)
+		resampled.LogVector2 = resampleLog(channel.LogVector2, times)
+		resampled.LogVector3 = resampleLog(channel.LogVector3, times)
+		resampled.LogVector4 = resampleLog(channel.LogVector4, times)
+		resampled.LogQAngle = resampleLog(channel.LogQAngle, times)
+		resampled.LogQuaternion = resampleLog(channel.LogQuaternion, times)
+		resampled.LogVMatrix = resampleLog(channel.LogVMatrix, times)
+		resampled.LogString = resampleLog(channel.LogString, times)
+		resampled.LogTime = resampleLog(channel.LogTime, times)
+		clip.Channels[i] = &resampled
+	}
+	return &clip
+}
+
+// keyRange returns the first and last key time over all channels.
+func (c *DmeChannelsClip) keyRange() (first, last Time, ok bool) {
+	add := func(times []Time) {
+		if len(times) == 0 {
+			return
+		}
+		if !ok || times[0] < first {
+			first = times[0]
+		}
+		if !ok || times[len(times)-1] > last {
+			last = times[len(times)-1]
+		}
+		ok = true
+	}
+	for _, channel := range c.Channels {
+		if channel == nil {
+			continue
+		}
+		for _, times := range channel.keyTimes() {
+			add(times)
+		}
+	}
+	return first, last, ok
+}
+
+// keyTimes returns the key times of every layer of the channel's log.
+func (c *DmeChannel) keyTimes() [][]Time {
+	var result [][]Time
+	add := func(times []Time) {
+		result = append(result, times)
+	}
+	eachLayerTimes(c.LogInt, add)
+	eachLayerTimes(c.LogFloat, add)
+	eachLayerTimes(c.LogBool, add)
+	eachLayerTimes(c.LogColor, add)
+	eachLayerTimes(c.LogVector2, add)
+	eachLayerTimes(c.LogVector3, add)
+	eachLayerTimes(c.LogVector4, add)
+	eachLayerTimes(c.LogQAngle, add)
+	eachLayerTimes(c.LogQuaternion, add)
+	eachLayerTimes(c.LogVMatrix, add)
+	eachLayerTimes(c.LogString, add)
+	eachLayerTimes(c.LogTime, add)
+	return result
+}
+
+func eachLayerTimes[T LogType](l *DmeLog[T], fn func([]Time)) {
+	if l == nil {
+		return
+	}
+	for _, layer := range l.Layers {
+		if layer != nil {
+			fn(layer.Times)
+		}
+	}
+}
+
+// Resample returns a layer with the log's value at each of times, which
+// must be ascending. Quaternion keys are kept in the hemisphere of the key
+// before them, so the result interpolates along the shortest arc.
+func (l *DmeLog[T]) Resample(times []Time) *DmeLogLayer[T] {
+	layer := &DmeLogLayer[T]{
+		Name:   l.Name,
+		Type:   l.Type + "Layer",
+		Times:  append([]Time(nil), times...),
+		Values: make([]T, len(times)),
+	}
+	for i, t := range times {
+		layer.Values[i] = l.Value(t)
+	}
+	if l.Type == "DmeQuaternionLog" {
+		for i := 1; i < len(layer.Values); i++ {
+			prev := any(layer.Values[i-1]).([4]float32)
+			cur := any(layer.Values[i]).([4]float32)
+			layer.Values[i] = any([4]float32(dmxmath.Quat(cur).Align(prev))).(T)
+		}
+	}
+	return layer
+}
+
+func resampleLog[T LogType](l *DmeLog[T], times []Time) *DmeLog[T] {
+	if l == nil {
+		return nil
+	}
+	resampled := *l
+	resampled.Layers = []*DmeLogLayer[T]{l.Resample(times)}
+	return &resampled
+}
@@ -0,0 +1,71 @@
+package dmx
+
+import "github.com/aoisensi/darkseer/dmx/dmxmath"
+
+// SkinMatrices returns, for each of the model's JointTransforms, the matrix
+// that moves a vertex from the model's bind pose to pose. The bind pose is
+// the model's bind state, or the rest pose of its own joints if it has
+// none; joints missing from either pose get the identity.
+func SkinMatrices(model *DmeModel, pose *Pose) []dmxmath.Mat4 {
+	bindPose := BindPose(model)
+	if bindPose == nil {
+		bindPose = EvaluatePose(model, nil, 0)
+	}
+	matrices := make([]dmxmath.Mat4, len(model.JointTransforms))
+	for i, transform := range model.JointTransforms {
+		matrices[i] = dmxmath.Mat4Identity()
+		if transform == nil {
+			continue
+		}
+		bind, posed := bindPose.Joint(transform.Name), pose.Joint(transform.Name)
+		if bind == nil || posed == nil {
+			continue
+		}
+		matrices[i] = posed.World.Mul(bind.World.Inverse())
+	}
+	return matrices
+}
+
+// Skin returns a copy of the vertex data posed by linear blend skinning:
+// each position is moved by the weighted sum of the matrices its joint
+// indices select. Weights are normalized per vertex and vertices without
+// weights stay in place. Because one normal can be shared by differently
+// weighted positions, the copy has one normal per face corner.
+func (vd *DmeVertexData) Skin(matrices []dmxmath.Mat4) *DmeVertexData {
+	skinned := *vd
+	jc := int(vd.JointCount)
+	blended := make([]dmxmath.Mat4, len(vd.Positions))
+	for i := range vd.Positions {
+		var m dmxmath.Mat4
+		var total float32
+		for j := 0; j < jc && i*jc+j < len(vd.JointWeights) && i*jc+j < len(vd.JointIndices); j++ {
+			w := vd.JointWeights[i*jc+j]
+			index := int(vd.JointIndices[i*jc+j])
+			if w == 0 || index < 0 || index >= len(matrices) {
+				continue
+			}
+			m = m.Add(matrices[index].Scale(w))
+			total += w
+		}
+		if total == 0 {
+			blended[i] = dmxmath.Mat4Identity()
+		} else {
+			blended[i] = m.Scale(1 / total)
+		}
+	}
+
+	skinned.Positions = make([][3]float32, len(vd.Positions))
+	for i, p := range vd.Positions {
+		skinned.Positions[i] = blended[i].TransformPoint(p)
+	}
+	if len(vd.NormalsIndices) == len(vd.PositionIndices) {
+		skinned.Normals = make([][3]float32, len(vd.NormalsIndices))
+		skinned.NormalsIndices = make([]int32, len(vd.NormalsIndices))
+		for c, n := range vd.NormalsIndices {
+			normalMatrix := blended[vd.PositionIndices[c]].Inverse().Transpose()
+			skinned.Normals[c] = normalMatrix.TransformVector(vd.Normals[n]).Normalize()
+			skinned.NormalsIndices[c] = int32(c)
+		}
+	}
+	return &skinned
+}
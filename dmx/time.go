@@ -0,0 +1,67 @@
+package dmx
+
+import (
+	"math"
+	"time"
+)
+
+// Time is a DMX time value, counted in ticks of 1/10000 second.
+type Time int32
+
+// TimeTicksPerSecond is the number of Time ticks in a second.
+const TimeTicksPerSecond = 10000
+
+// TimeFromSeconds returns the Time nearest to s seconds.
+func TimeFromSeconds(s float64) Time {
+	return Time(math.Round(s * TimeTicksPerSecond))
+}
+
+// TimeFromFrames returns the Time of frame f at rate frames per second.
+func TimeFromFrames(f, rate float64) Time {
+	return TimeFromSeconds(f / rate)
+}
+
+func (t Time) Seconds() float64 {
+	return float64(t) / TimeTicksPerSecond
+}
+
+// Frames returns t as a frame number at rate frames per second.
+func (t Time) Frames(rate float64) float64 {
+	return t.Seconds() * rate
+}
+
+func (t Time) Duration() time.Duration {
+	return time.Duration(t) * (time.Second / TimeTicksPerSecond)
+}
+
+// parseTime accepts the time attribute type as well as the int32 older
+// files store times as.
+func parseTime(v any) Time {
+	switch v := v.(type) {
+	case time.Duration:
+		return Time(v / (time.Second / TimeTicksPerSecond))
+	case int32:
+		return Time(v)
+	case float32:
+		return TimeFromSeconds(float64(v))
+	}
+	return 0
+}
+
+func parseTimes(v any) []Time {
+	switch v := v.(type) {
+	case []time.Duration:
+		times := make([]Time, len(v))
+		for i, d := range v {
+			times[i] = parseTime(d)
+		}
+		return times
+	case []int32:
+		times := make([]Time, len(v))
+		for i, d := range v {
+			times[i] = Time(d)
+		}
+		return times
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package dmx
+
+import "testing"
+
+func TestTimeFrameConversion(t *testing.T) {
+	frame := &DmeTimeFrame{StartTime: 20000, OffsetTime: 5000, Scale: 2}
+	clip := &DmeChannelsClip{TimeFrame: frame}
+	tests := []struct {
+		log, parent, local Time
+	}{
+		{log: 5000, parent: 20000, local: 0},
+		{log: 25000, parent: 30000, local: 10000},
+		{log: 1000, parent: 18000, local: -2000},
+	}
+	for _, tt := range tests {
+		if got := frame.ToParent(tt.log); got != tt.parent {
+			t.Errorf("ToParent(%d) = %d, want %d", tt.log, got, tt.parent)
+		}
+		if got := frame.ToChild(tt.parent); got != tt.log {
+			t.Errorf("ToChild(%d) = %d, want %d", tt.parent, got, tt.log)
+		}
+		if got := clip.LocalTime(tt.log); got != tt.local {
+			t.Errorf("LocalTime(%d) = %d, want %d", tt.log, got, tt.local)
+		}
+		if got := clip.LogTime(tt.local); got != tt.log {
+			t.Errorf("LogTime(%d) = %d, want %d", tt.local, got, tt.log)
+		}
+	}
+}
+
+func TestTimeFrameDefault(t *testing.T) {
+	var frame *DmeTimeFrame
+	if got := frame.ToParent(1234); got != 1234 {
+		t.Errorf("nil ToParent(1234) = %d", got)
+	}
+	clip := &DmeChannelsClip{}
+	if got := clip.LocalTime(1234); got != 1234 {
+		t.Errorf("LocalTime without a time frame = %d", got)
+	}
+	if got := clip.LogTime(1234); got != 1234 {
+		t.Errorf("LogTime without a time frame = %d", got)
+	}
+}
+
+func TestTimeSeconds(t *testing.T) {
+	if got := TimeFromSeconds(1.5); got != 15000 {
+		t.Errorf("TimeFromSeconds(1.5) = %d", got)
+	}
+	if got := TimeFromFrames(3, 30); got != 1000 {
+		t.Errorf("TimeFromFrames(3, 30) = %d", got)
+	}
+	if got := Time(25000).Seconds(); got != 2.5 {
+		t.Errorf("Seconds() = %g", got)
+	}
+}
+
+func TestLogLayerTrim(t *testing.T) {
+	layer := &DmeLogLayer[float32]{
+		Times:  []Time{0, 10000, 20000},
+		Values: []float32{0, 10, 20},
+	}
+	trimmed := layer.Trim(5000)
+	wantTimes := []Time{5000, 10000, 20000}
+	wantValues := []float32{5, 10, 20}
+	if len(trimmed.Times) != len(wantTimes) {
+		t.Fatalf("Trim(5000) times = %v, want %v", trimmed.Times, wantTimes)
+	}
+	for i := range wantTimes {
+		if trimmed.Times[i] != wantTimes[i] || trimmed.Values[i] != wantValues[i] {
+			t.Errorf("Trim(5000) key %d = %d: %g, want %d: %g", i, trimmed.Times[i], trimmed.Values[i], wantTimes[i], wantValues[i])
+		}
+	}
+	if got := layer.Trim(10000); len(got.Times) != 2 || got.Times[0] != 10000 {
+		t.Errorf("Trim(10000) times = %v, want [10000 20000]", got.Times)
+	}
+	if got := layer.Trim(0); got != layer {
+		t.Errorf("Trim(0) copied a layer with no key before 0")
+	}
+}
@@ -0,0 +1,40 @@
+package dmx
+
+import "github.com/aoisensi/darkseer/dmx/dmxmath"
+
+// Matrix returns the local transformation matrix, translation * rotation *
+// scale.
+func (t *DmeTransform) Matrix() dmxmath.Mat4 {
+	if t == nil {
+		return dmxmath.Mat4Identity()
+	}
+	return dmxmath.Compose(t.Position, t.Orientation, t.Scale)
+}
+
+// WalkDag calls fn for every node under children, parents before their
+// children, with the node's world matrix. parent is the world matrix of the
+// node owning children.
+func WalkDag(children []IDag, parent dmxmath.Mat4, fn func(dag IDag, world dmxmath.Mat4)) {
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		dag := child.Dag()
+		world := parent.Mul(dag.Transform.Matrix())
+		fn(child, world)
+		WalkDag(dag.Children, world, fn)
+	}
+}
+
+// WorldMatrices returns the world matrix of every DmeDag, DmeJoint and
+// DmeAttachment under the model.
+func (m *DmeModel) WorldMatrices() map[IDag]dmxmath.Mat4 {
+	result := make(map[IDag]dmxmath.Mat4)
+	if m == nil {
+		return result
+	}
+	WalkDag(m.Children, m.Transform.Matrix(), func(dag IDag, world dmxmath.Mat4) {
+		result[dag] = world
+	})
+	return result
+}
@@ -5,13 +5,13 @@ go 1.19
 require (
 	github.com/google/uuid v1.3.0
 	github.com/k0kubun/pp/v3 v3.2.0
+	github.com/qmuntal/gltf v0.23.1
+	github.com/samber/lo v1.37.0
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.16 // indirect
-	github.com/qmuntal/gltf v0.23.1 // indirect
-	github.com/samber/lo v1.37.0 // indirect
 	golang.org/x/exp v0.0.0-20220303212507-bbda1eaf7a17 // indirect
 	golang.org/x/sys v0.0.0-20220811171246-fbc7d0a398ab // indirect
 	golang.org/x/text v0.3.7 // indirect